@@ -7,29 +7,35 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
-	"database/sql"
-
 	"github.com/go-redis/redis/v8"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 
 	"github.com/pampatzoglou/chain-view/config"
+	"github.com/pampatzoglou/chain-view/internal/admin"
+	"github.com/pampatzoglou/chain-view/internal/balances"
+	"github.com/pampatzoglou/chain-view/internal/database"
 	"github.com/pampatzoglou/chain-view/internal/endpoints"
+	"github.com/pampatzoglou/chain-view/internal/ingest"
 	"github.com/pampatzoglou/chain-view/internal/logging"
+	"github.com/pampatzoglou/chain-view/internal/metrics"
+	"github.com/pampatzoglou/chain-view/internal/proxy"
 )
 
+// configPath is the configuration file loaded at startup and on every reload
+const configPath = "config/config.yaml"
+
 var logger *logging.Logger
-var db *sql.DB
 var redisClient *redis.Client
 
 func main() {
 	// Load the configuration from the YAML file
-	cfg, err := config.LoadConfig("config/config.yaml")
+	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
 		fmt.Printf("Could not load config: %v\n", err)
 		os.Exit(1)
@@ -38,18 +44,6 @@ func main() {
 	// Initialize logger after loading configuration
 	logger = logging.NewLogger(cfg.Server.Logging.Level)
 
-	// Database connection setup
-	// db, err = sql.Open("postgres", cfg.Database.URL)
-	// if err != nil {
-	// 	logger.WithError(err).Fatal("Failed to connect to the database")
-	// }
-	// defer db.Close()
-
-	// err = db.Ping()
-	// if err != nil {
-	// 	logger.WithError(err).Fatal("Failed to ping the database")
-	// }
-
 	// Redis connection setup
 	// redisClient = redis.NewClient(&redis.Options{
 	// 	Addr: cfg.Redis.URL,
@@ -61,32 +55,46 @@ func main() {
 	// 	logger.WithError(err).Fatal("Failed to connect to Redis")
 	// }
 
-	// Create endpoint pools based on the loaded configuration
-	pools, poolErrors := endpoints.CreatePools(cfg.Chains, logger)
-	if len(poolErrors) > 0 {
-		for _, err := range poolErrors {
-			logger.WithError(err).Error("Error creating endpoint pool")
-		}
-		if len(pools) == 0 {
-			logger.Fatal("Failed to create any endpoint pools")
-		}
-	}
+	// Register the Prometheus metrics shared by every chain
+	metricsManager := metrics.NewMetricsManager(logger, prometheus.DefaultRegisterer)
+	metricsManager.RegisterMetrics()
 
 	// Create a cancelable context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Start processing endpoints for each pool
-	var wg sync.WaitGroup
-	for _, pool := range pools {
-		wg.Add(1)
-		go func(p *endpoints.EndpointPool) {
-			defer wg.Done()
-			p.ProcessEndpoints(ctx, cfg.GlobalSettings.MaxWorkers, logger)
-		}(pool)
+	// Database connection setup. Probe history is written in the background until the
+	// pools have stopped; without a database the service keeps running and only the
+	// history, ingestion and balance tracking are missing.
+	var db *database.DB
+	var checks *database.CheckWriter
+	checksCtx, stopChecks := context.WithCancel(context.Background())
+	defer stopChecks()
+	if cfg.Database.URL == "" {
+		logger.Warn("No database configured, probe history, ingestion and balance tracking are disabled")
+	} else if db, err = database.Initialize(cfg.Database.URL, logger, metricsManager); err != nil {
+		logger.WithError(err).Error("Failed to connect to the database, probe history, ingestion and balance tracking are disabled")
+	} else {
+		defer db.Close()
+		checks = database.NewCheckWriter(db)
+		go checks.Run(checksCtx)
+	}
 
-		// Start logging circuit breaker metrics
-		go pool.LogCircuitBreakerMetrics(ctx, logger)
+	// Create and start endpoint pools based on the loaded configuration. Chains with
+	// ingestion enabled store their blocks and transactions, and the balances of watched
+	// addresses are tracked, while their pool runs.
+	pools := endpoints.NewPoolSet(ctx, logger, metricsManager, checks)
+	if db != nil {
+		pools.AddTask(ingest.NewIngester(db, logger, metricsManager).Run)
+		pools.AddTask(balances.NewWatcher(db, logger, metricsManager).Run)
+	}
+	if poolErrors := pools.Apply(cfg.Chains, cfg.GlobalSettings); len(poolErrors) > 0 {
+		for _, err := range poolErrors {
+			logger.WithError(err).Error("Error creating endpoint pool")
+		}
+		if len(pools.Pools()) == 0 {
+			logger.Fatal("Failed to create any endpoint pools")
+		}
 	}
 
 	// Set up HTTP handlers
@@ -94,6 +102,55 @@ func main() {
 	http.HandleFunc("/healthz/start", startupCheckHandler)
 	http.HandleFunc("/healthz/level", handleLogLevelUpdate)
 	http.Handle("/healthz/metrics", promhttp.Handler())
+	rpcProxy := proxy.NewHandler(pools.Pools(), logger, metricsManager)
+	pools.OnChange(rpcProxy.SetPools)
+	http.Handle("POST /rpc/{chain}", rpcProxy)
+	http.HandleFunc("POST /rpc/{chain}/quorum", rpcProxy.ServeQuorum)
+
+	// The admin API can take endpoints out of rotation, so it gets its own listener
+	// instead of sharing the public proxy port
+	adminToken, err := cfg.Server.Admin.Token.Resolve()
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to resolve the admin API token")
+	}
+	if adminToken == "" {
+		logger.WithFields(logrus.Fields{
+			"listen_address": cfg.Server.Admin.ListenAddress,
+		}).Warn("No admin API token configured, the admin API is open to anyone who can reach its address")
+	}
+	adminAPI := admin.NewHandler(pools.Pools(), admin.Auth{
+		Token:             adminToken,
+		TrustProxyHeaders: cfg.Server.Admin.TrustProxyHeaders,
+	}, logger)
+	pools.OnChange(adminAPI.SetPools)
+	adminServer := &http.Server{
+		Addr:         cfg.Server.Admin.ListenAddress,
+		Handler:      adminAPI.Routes(),
+		ReadTimeout:  cfg.GlobalSettings.RequestTimeout.Duration,
+		WriteTimeout: cfg.GlobalSettings.RequestTimeout.Duration,
+	}
+
+	// Reload the configuration on SIGHUP and whenever the file changes
+	reload := func(trigger string) {
+		reloadConfig(pools, metricsManager, trigger)
+	}
+	if err := config.Watch(ctx, configPath, func() { reload("file") }, func(err error) {
+		logger.WithError(err).Warn("Config watcher error")
+	}); err != nil {
+		logger.WithError(err).Warn("Config file watching disabled, reload with SIGHUP")
+	}
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reloadChan:
+				reload("signal")
+			}
+		}
+	}()
 
 	// Start the HTTP server
 	server := &http.Server{
@@ -112,6 +169,12 @@ func main() {
 			logger.WithError(err).Fatal("Server failed to start")
 		}
 	}()
+	go func() {
+		logger.WithFields(logrus.Fields{"listen_address": adminServer.Addr}).Info("Starting admin API server")
+		if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.WithError(err).Fatal("Admin API server failed to start")
+		}
+	}()
 
 	// Wait for a shutdown signal
 	<-shutdownChan
@@ -128,13 +191,44 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.WithError(err).Fatal("Server forced to shutdown")
 	}
+	if err := adminServer.Shutdown(shutdownCtx); err != nil {
+		logger.WithError(err).Error("Admin API server forced to shutdown")
+	}
 
-	// Wait for all endpoint processing to complete
-	wg.Wait()
+	// Wait for all endpoint processing to complete, then for the remaining probe history
+	pools.Wait()
+	stopChecks()
+	checks.Wait()
 
 	logger.Info("Server exited gracefully")
 }
 
+// reloadConfig loads the configuration file again and applies it to the running pools
+// and the log level. Chains that fail to apply keep their previous configuration.
+func reloadConfig(pools *endpoints.PoolSet, metricsManager *metrics.MetricsManager, trigger string) {
+	fields := logrus.Fields{"trigger": trigger, "path": configPath}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		metricsManager.RecordConfigReload(false)
+		logger.WithError(err).WithFields(fields).Error("Config reload failed, keeping the current configuration")
+		return
+	}
+
+	logger.SetLevel(cfg.Server.Logging.Level)
+	if errs := pools.Apply(cfg.Chains, cfg.GlobalSettings); len(errs) > 0 {
+		metricsManager.RecordConfigReload(false)
+		for _, err := range errs {
+			logger.WithError(err).WithFields(fields).Error("Config reload failed for chain, keeping its current configuration")
+		}
+		return
+	}
+
+	metricsManager.RecordConfigReload(true)
+	fields["chains"] = len(cfg.Chains)
+	logger.WithFields(fields).Info("Config reloaded")
+}
+
 // Health check handler
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
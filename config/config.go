@@ -1,18 +1,19 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
-	"io/ioutil"
-
 	"gopkg.in/yaml.v2"
 )
 
 // Config represents the top-level configuration structure
 type Config struct {
 	Server         ServerConfig   `yaml:"server"`
-	Database       DatabaseConfig `yaml:"database"` // Database configuration at the top level
+	Database       DatabaseConfig `yaml:"database"` // Database configuration at the top level, not under server
 	Redis          RedisConfig    `yaml:"redis"`
 	Chains         []ChainConfig  `yaml:"chains"`
 	GlobalSettings GlobalSettings `yaml:"global_settings"`
@@ -22,6 +23,14 @@ type Config struct {
 type ServerConfig struct {
 	Port    int           `yaml:"port"`
 	Logging LoggingConfig `yaml:"logging"`
+	Admin   AdminConfig   `yaml:"admin"` // Admin API, served apart from the JSON-RPC proxy
+}
+
+// AdminConfig represents where the admin API is served and who may call it
+type AdminConfig struct {
+	ListenAddress     string      `yaml:"listen_address"`      // host:port of the admin API, never the proxy port
+	Token             SecretValue `yaml:"token"`               // Bearer token required by every admin request, when set
+	TrustProxyHeaders bool        `yaml:"trust_proxy_headers"` // Take the audited caller from X-Forwarded-User and similar headers
 }
 
 // LoggingConfig represents the logging configuration
@@ -44,16 +53,118 @@ type ChainConfig struct {
 	ChainID         int              `yaml:"chain_id"`
 	Network         string           `yaml:"network"`
 	Endpoints       []EndpointConfig `yaml:"endpoints"`
-	PoolingStrategy string           `yaml:"pooling_strategy"`
+	PoolingStrategy string           `yaml:"pooling_strategy"` // round_robin, weighted, priority, random or least_latency
 	RetryCount      int              `yaml:"retry_count"`
-	RetryBackoff    Duration         `yaml:"retry_backoff"`
+	RetryBackoff    Duration         `yaml:"retry_backoff"`     // Base delay of the exponential retry backoff
+	RetryBackoffMax Duration         `yaml:"retry_backoff_max"` // Upper bound of the retry backoff
+	RetryPolicy     string           `yaml:"retry_policy"`      // same, next or best
+	SplitBatches    bool             `yaml:"split_batches"`     // Spread proxied JSON-RPC batches over the available endpoints
+	MaxBlockLag     uint64           `yaml:"max_block_lag"`     // Blocks an endpoint may trail the best head before it is degraded, 0 disables
+	ReorgWindow     uint64           `yaml:"reorg_window"`      // Recent heights whose hashes are kept for reorg detection
+
+	FinalityPollInterval   Duration             `yaml:"finality_poll_interval"`   // How often the finalized and safe blocks are polled
+	FinalityStallThreshold Duration             `yaml:"finality_stall_threshold"` // Alert when the finalized block does not advance for this long, 0 disables
+	ChainIDCheckInterval   Duration             `yaml:"chain_id_check_interval"`  // How often endpoints are asked for their chain ID, defaults to 5m
+	CircuitBreaker         CircuitBreakerConfig `yaml:"circuit_breaker"`          // Defaults for every endpoint of the chain
+	Quorum                 QuorumConfig         `yaml:"quorum"`                   // Quorum reads across endpoints
+	Ingestion              IngestionConfig      `yaml:"ingestion"`                // Block and transaction ingestion into the database
+	BackgroundRateLimit    float64              `yaml:"background_rate_limit"`    // Requests per second ingestion and balance tracking may send, defaults to 2
+	Balances               BalanceConfig        `yaml:"balances"`                 // Balance tracking of watched addresses
+}
+
+// BalanceConfig represents the addresses whose balance is tracked on a chain
+type BalanceConfig struct {
+	Addresses    []string `yaml:"addresses"`     // Watched in addition to the chain's rows in watched_addresses
+	PollInterval Duration `yaml:"poll_interval"` // How often balances are read, 0 reads them at every new block
+}
+
+// IngestionConfig represents how blocks and transactions of a chain are ingested
+type IngestionConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	StartBlock    uint64   `yaml:"start_block"`   // First block ingested when no cursor is stored, 0 starts at the head
+	Confirmations uint64   `yaml:"confirmations"` // Blocks ingestion stays behind the head, 0 follows the head
+	PollInterval  Duration `yaml:"poll_interval"` // How often the head is checked for new blocks, defaults to 5s
+}
+
+// Pooling strategies accepted by pooling_strategy
+const (
+	PoolingRoundRobin   = "round_robin"
+	PoolingWeighted     = "weighted"
+	PoolingPriority     = "priority"
+	PoolingRandom       = "random"
+	PoolingLeastLatency = "least_latency"
+)
+
+// PoolingStrategies lists every accepted pooling strategy. The endpoints package
+// implements exactly these.
+var PoolingStrategies = []string{PoolingRoundRobin, PoolingWeighted, PoolingPriority, PoolingRandom, PoolingLeastLatency}
+
+// DefaultQuorumSize is the number of endpoints a quorum read asks when quorum.size is unset
+const DefaultQuorumSize = 3
+
+// QuorumConfig represents how many endpoints a quorum read asks and how many must agree
+type QuorumConfig struct {
+	Size      int `yaml:"size"`      // Endpoints asked per read, defaults to 3
+	Threshold int `yaml:"threshold"` // Endpoints that must agree, defaults to a majority of size
+}
+
+// EffectiveSize returns the number of endpoints a quorum read asks
+func (q QuorumConfig) EffectiveSize() int {
+	if q.Size <= 0 {
+		return DefaultQuorumSize
+	}
+	return q.Size
+}
+
+// CircuitBreakerConfig represents the circuit breaker thresholds of a chain or endpoint.
+// Zero values inherit from the chain, then from the built-in defaults.
+type CircuitBreakerConfig struct {
+	FailureThreshold    int      `yaml:"failure_threshold"`      // Consecutive failures that open the breaker
+	FailureRate         float64  `yaml:"failure_rate"`           // Failure ratio (0-1) within the window that opens the breaker
+	Window              Duration `yaml:"window"`                 // Rolling window for the failure rate
+	MinRequests         int      `yaml:"min_requests"`           // Requests required in the window before the rate applies
+	OpenDuration        Duration `yaml:"open_duration"`          // Time spent open before trial requests are allowed
+	HalfOpenMaxRequests int      `yaml:"half_open_max_requests"` // Trial requests allowed while half-open
 }
 
 // EndpointConfig represents a single endpoint configuration
 type EndpointConfig struct {
-	Name    string   `yaml:"name"`
-	URL     string   `yaml:"url"`
-	Timeout Duration `yaml:"timeout"`
+	Name     string   `yaml:"name"`
+	URL      string   `yaml:"url"`
+	Timeout  Duration `yaml:"timeout"`  // Defaults to global_settings.request_timeout
+	Weight   int      `yaml:"weight"`   // Relative share of traffic for the weighted strategy
+	Priority int      `yaml:"priority"` // Lower values are preferred by the priority strategy
+
+	RateLimit float64     `yaml:"rate_limit"` // Requests per second sent to the endpoint
+	Burst     int         `yaml:"burst"`      // Maximum burst above the rate limit
+	Quota     QuotaConfig `yaml:"quota"`      // Provider request quotas
+
+	MaxBatchSize int      `yaml:"max_batch_size"` // Largest JSON-RPC batch the provider accepts, 0 for no limit
+	StaleTimeout Duration `yaml:"stale_timeout"`  // Reconnect a ws:// or wss:// subscription after this long without messages
+
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"` // Overrides the chain circuit breaker settings
+
+	Headers   map[string]SecretValue `yaml:"headers"`    // Extra HTTP headers sent with every request, e.g. API keys
+	BasicAuth *BasicAuthConfig       `yaml:"basic_auth"` // HTTP basic auth credentials
+	JWT       *JWTConfig             `yaml:"jwt"`        // HS256 bearer tokens, as used by the execution engine API
+}
+
+// BasicAuthConfig represents HTTP basic auth credentials
+type BasicAuthConfig struct {
+	Username SecretValue `yaml:"username"`
+	Password SecretValue `yaml:"password"`
+}
+
+// JWTConfig represents the signing settings of HS256 bearer tokens
+type JWTConfig struct {
+	Secret SecretValue `yaml:"secret"` // Hex-encoded 32-byte secret, as in a jwt.hex file
+	ID     string      `yaml:"id"`     // Optional id claim identifying this client
+}
+
+// QuotaConfig represents the request quotas of a provider plan. Zero means unlimited.
+type QuotaConfig struct {
+	Daily   int64 `yaml:"daily"`
+	Monthly int64 `yaml:"monthly"`
 }
 
 // Duration is a wrapper around time.Duration to handle YAML duration parsing
@@ -75,25 +186,148 @@ func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// SecretValue is a credential read from plain text, an environment variable or a file.
+// In YAML it is either a plain string or a mapping with one of value, env or file.
+type SecretValue struct {
+	Value string `yaml:"value"`
+	Env   string `yaml:"env"`
+	File  string `yaml:"file"`
+}
+
+// UnmarshalYAML accepts a plain string as well as the value/env/file mapping
+func (s *SecretValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var plain string
+	if err := unmarshal(&plain); err == nil {
+		*s = SecretValue{Value: plain}
+		return nil
+	}
+
+	type rawSecretValue SecretValue
+	var raw rawSecretValue
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	*s = SecretValue(raw)
+	return nil
+}
+
+// IsSet reports whether any source is configured
+func (s SecretValue) IsSet() bool {
+	return s.Value != "" || s.Env != "" || s.File != ""
+}
+
+// Resolve returns the secret from its configured source. File contents are trimmed of
+// surrounding whitespace so mounted secrets may end with a newline.
+func (s SecretValue) Resolve() (string, error) {
+	sources := 0
+	for _, v := range []string{s.Value, s.Env, s.File} {
+		if v != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", fmt.Errorf("only one of value, env or file may be set")
+	}
+
+	switch {
+	case s.Env != "":
+		v, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		return v, nil
+	case s.File != "":
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", fmt.Errorf("error reading secret file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return s.Value, nil
+	}
+}
+
+// String hides the secret so it never ends up in logs
+func (s SecretValue) String() string {
+	switch {
+	case s.Env != "":
+		return "env:" + s.Env
+	case s.File != "":
+		return "file:" + s.File
+	case s.Value != "":
+		return "[redacted]"
+	default:
+		return ""
+	}
+}
+
+// MarshalJSON hides the secret in JSON output such as structured log fields
+func (s SecretValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
 // GlobalSettings represents the global settings configuration
 type GlobalSettings struct {
-	RequestTimeout Duration `yaml:"request_timeout"`
-	MaxRetries     int      `yaml:"max_retries"`
-	MaxWorkers     int      `yaml:"max_workers"`
-	RetryBackoff   Duration `yaml:"retry_backoff"`
+	RequestTimeout  Duration `yaml:"request_timeout"`
+	MaxRetries      int      `yaml:"max_retries"`
+	MaxWorkers      int      `yaml:"max_workers"`
+	RetryBackoff    Duration `yaml:"retry_backoff"`
+	RetryBackoffMax Duration `yaml:"retry_backoff_max"`
 }
 
-// LoadConfig loads the configuration from the specified YAML file
+// Default returns the configuration used for every value the file and environment leave unset
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:    9000,
+			Logging: LoggingConfig{Level: "info"},
+			Admin:   AdminConfig{ListenAddress: "127.0.0.1:9001"},
+		},
+		GlobalSettings: GlobalSettings{
+			RequestTimeout: Duration{10 * time.Second},
+			MaxRetries:     3,
+			MaxWorkers:     10,
+		},
+	}
+}
+
+// LoadConfig loads the configuration in layers: the defaults, then the YAML file, then
+// CHAINVIEW_* environment overrides. Unknown fields are rejected, ${VAR} references in the
+// string values of the file are expanded, and the result is validated as a whole.
 func LoadConfig(filename string) (*Config, error) {
-	data, err := ioutil.ReadFile(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	config := Default()
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
 		return nil, fmt.Errorf("error parsing config file: %w", err)
 	}
 
-	return &config, nil
+	if err := expandEnv(config, os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("error expanding config file: %w", err)
+	}
+
+	if err := applyEnv(config, os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("error applying environment overrides: %w", err)
+	}
+	config.applyDefaults()
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return config, nil
+}
+
+// applyDefaults fills values whose default depends on other settings
+func (c *Config) applyDefaults() {
+	for i := range c.Chains {
+		for j := range c.Chains[i].Endpoints {
+			endpoint := &c.Chains[i].Endpoints[j]
+			if endpoint.Timeout.Duration == 0 {
+				endpoint.Timeout = c.GlobalSettings.RequestTimeout
+			}
+		}
+	}
 }
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the name of every environment variable that overrides a configuration
+// value. The rest of the name is the YAML path in upper case joined by underscores, for
+// example CHAINVIEW_DATABASE_URL or CHAINVIEW_GLOBAL_SETTINGS_MAX_WORKERS.
+// Values inside lists, such as chains and endpoints, are set in the file or with ${VAR}.
+const EnvPrefix = "CHAINVIEW_"
+
+// envReference matches ${VAR} and ${VAR:-default}, and the $${ escape for a literal ${
+var envReference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} references in the string values of a parsed configuration.
+// Expanding after parsing keeps values with YAML syntax in them, such as " #", ": " or
+// newlines, intact. A reference to an unset variable without a default is an error, so a
+// missing secret does not silently become an empty string.
+func expandEnv(c *Config, lookup func(string) (string, bool)) error {
+	missing := make(map[string]bool)
+	expandEnvValue(reflect.ValueOf(c).Elem(), lookup, missing)
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("undefined environment variables: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// expandEnvValue expands the references in every string reachable from v, recording the
+// names of unset variables in missing.
+func expandEnvValue(v reflect.Value, lookup func(string) (string, bool), missing map[string]bool) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(expandString(v.String(), lookup, missing))
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			expandEnvValue(v.Elem(), lookup, missing)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				expandEnvValue(v.Field(i), lookup, missing)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			expandEnvValue(v.Index(i), lookup, missing)
+		}
+	case reflect.Map:
+		// Map values are not addressable, so they are expanded in a copy
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			expandEnvValue(value, lookup, missing)
+			v.SetMapIndex(iter.Key(), value)
+		}
+	}
+}
+
+// expandString replaces the references in s.
+func expandString(s string, lookup func(string) (string, bool), missing map[string]bool) string {
+	return envReference.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		match := envReference.FindStringSubmatch(ref)
+		name := match[1]
+		if value, ok := lookup(name); ok {
+			return value
+		}
+		if strings.Contains(ref, ":-") {
+			return match[2]
+		}
+		missing[name] = true
+		return ""
+	})
+}
+
+// applyEnv overrides configuration values from CHAINVIEW_* environment variables.
+func applyEnv(c *Config, lookup func(string) (string, bool)) error {
+	return applyEnvStruct(reflect.ValueOf(c).Elem(), strings.TrimSuffix(EnvPrefix, "_"), lookup)
+}
+
+// applyEnvStruct walks the fields of a struct by their YAML names and sets every scalar
+// field whose environment variable is set. Lists and maps are skipped.
+func applyEnvStruct(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(tag)
+		value := v.Field(i)
+
+		if value.Kind() == reflect.Struct && value.Type() != reflect.TypeOf(Duration{}) {
+			if err := applyEnvStruct(value, name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setScalar(value, raw); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setScalar parses raw into a string, number, boolean or Duration field.
+func setScalar(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(Duration{}) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(Duration{d}))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigExpandsEnvAfterParsing(t *testing.T) {
+	t.Setenv("TEST_NODE_HOST", "node.example.com")
+	t.Setenv("TEST_API_KEY", "a #b: c\nd")
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(`chains:
+  - network: mainnet
+    chain_id: 1
+    endpoints:
+      - name: node
+        url: https://${TEST_NODE_HOST}/rpc  # ${UNSET_IN_COMMENT}
+        headers:
+          X-Api-Key: ${TEST_API_KEY}
+      - name: fallback
+        url: "${TEST_FALLBACK_URL:-https://fallback.example.com}"
+        headers:
+          X-Api-Key: $${LITERAL}
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadConfig(file)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	endpoints := c.Chains[0].Endpoints
+	for _, tt := range []struct{ got, want string }{
+		{endpoints[0].URL, "https://node.example.com/rpc"},
+		{endpoints[0].Headers["X-Api-Key"].Value, "a #b: c\nd"},
+		{endpoints[1].URL, "https://fallback.example.com"},
+		{endpoints[1].Headers["X-Api-Key"].Value, "${LITERAL}"},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigRejectsUndefinedEnv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("database:\n  url: ${TEST_UNDEFINED_DATABASE_URL}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(file); err == nil {
+		t.Fatal("LoadConfig succeeded with an undefined variable")
+	}
+}
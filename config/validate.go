@@ -0,0 +1,233 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// validLogLevels are the levels accepted by server.logging.level
+var validLogLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+// addressPattern matches a 0x-prefixed 20-byte hex address
+var addressPattern = regexp.MustCompile(`^0[xX][0-9a-fA-F]{40}$`)
+
+// validURLSchemes are the schemes an endpoint URL may use
+var validURLSchemes = map[string]bool{"http": true, "https": true, "ws": true, "wss": true}
+
+// validRetryPolicies are the values accepted by retry_policy
+var validRetryPolicies = map[string]bool{"same": true, "next": true, "best": true}
+
+// Validate checks the whole configuration and reports every problem at once.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		v.addf("server.port: must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	if !validLogLevels[c.Server.Logging.Level] {
+		v.addf("server.logging.level: must be one of debug, info, warn or error, got %q", c.Server.Logging.Level)
+	}
+
+	if _, port, err := net.SplitHostPort(c.Server.Admin.ListenAddress); err != nil {
+		v.addf("server.admin.listen_address: must be host:port, got %q", c.Server.Admin.ListenAddress)
+	} else if port == strconv.Itoa(c.Server.Port) {
+		v.addf("server.admin.listen_address: must not use the proxy port %d", c.Server.Port)
+	}
+	if _, err := c.Server.Admin.Token.Resolve(); err != nil {
+		v.addf("server.admin.token: %v", err)
+	}
+
+	g := c.GlobalSettings
+	if g.RequestTimeout.Duration <= 0 {
+		v.addf("global_settings.request_timeout: must be positive")
+	}
+	if g.MaxWorkers <= 0 {
+		v.addf("global_settings.max_workers: must be positive")
+	}
+	if g.MaxRetries < 0 {
+		v.addf("global_settings.max_retries: must not be negative")
+	}
+	v.backoff("global_settings", g.RetryBackoff, g.RetryBackoffMax)
+
+	if len(c.Chains) == 0 {
+		v.addf("chains: at least one chain is required")
+	}
+	networks := make(map[string]bool, len(c.Chains))
+	chainIDs := make(map[int]bool, len(c.Chains))
+	for i, chain := range c.Chains {
+		path := fmt.Sprintf("chains[%d]", i)
+		if chain.Network == "" {
+			v.addf("%s.network: is required", path)
+		} else {
+			path = fmt.Sprintf("chains[%s]", chain.Network)
+			if networks[chain.Network] {
+				v.addf("%s.network: is configured more than once", path)
+			}
+			networks[chain.Network] = true
+		}
+		if chain.ChainID <= 0 {
+			v.addf("%s.chain_id: must be positive", path)
+		} else if chainIDs[chain.ChainID] {
+			v.addf("%s.chain_id: %d is used by another chain", path, chain.ChainID)
+		}
+		chainIDs[chain.ChainID] = true
+		v.chain(path, chain)
+	}
+
+	return v.err()
+}
+
+// validator collects validation problems.
+type validator struct {
+	problems []error
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Errorf(format, args...))
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.problems...)
+}
+
+// backoff checks a retry backoff base and cap.
+func (v *validator) backoff(path string, base, max Duration) {
+	if base.Duration < 0 {
+		v.addf("%s.retry_backoff: must not be negative", path)
+	}
+	if max.Duration < 0 {
+		v.addf("%s.retry_backoff_max: must not be negative", path)
+	}
+	if max.Duration > 0 && max.Duration < base.Duration {
+		v.addf("%s.retry_backoff_max: must not be less than retry_backoff", path)
+	}
+}
+
+// chain checks the settings and endpoints of a chain.
+func (v *validator) chain(path string, chain ChainConfig) {
+	if chain.PoolingStrategy != "" && !slices.Contains(PoolingStrategies, chain.PoolingStrategy) {
+		v.addf("%s.pooling_strategy: must be one of %s, got %q", path, listOr(PoolingStrategies), chain.PoolingStrategy)
+	}
+	if chain.RetryCount < 0 {
+		v.addf("%s.retry_count: must not be negative", path)
+	}
+	if chain.RetryPolicy != "" && !validRetryPolicies[chain.RetryPolicy] {
+		v.addf("%s.retry_policy: must be one of same, next or best, got %q", path, chain.RetryPolicy)
+	}
+	v.backoff(path, chain.RetryBackoff, chain.RetryBackoffMax)
+	if chain.FinalityPollInterval.Duration < 0 {
+		v.addf("%s.finality_poll_interval: must not be negative", path)
+	}
+	if chain.FinalityStallThreshold.Duration < 0 {
+		v.addf("%s.finality_stall_threshold: must not be negative", path)
+	}
+	if chain.ChainIDCheckInterval.Duration < 0 {
+		v.addf("%s.chain_id_check_interval: must not be negative", path)
+	}
+	if chain.BackgroundRateLimit < 0 {
+		v.addf("%s.background_rate_limit: must not be negative", path)
+	}
+	if chain.Ingestion.PollInterval.Duration < 0 {
+		v.addf("%s.ingestion.poll_interval: must not be negative", path)
+	}
+	if chain.Balances.PollInterval.Duration < 0 {
+		v.addf("%s.balances.poll_interval: must not be negative", path)
+	}
+	for i, address := range chain.Balances.Addresses {
+		if !addressPattern.MatchString(address) {
+			v.addf("%s.balances.addresses[%d]: must be a 0x-prefixed 20-byte hex address, got %q", path, i, address)
+		}
+	}
+	if chain.Quorum.Size < 0 || chain.Quorum.Threshold < 0 {
+		v.addf("%s.quorum: size and threshold must not be negative", path)
+	} else if size := chain.Quorum.EffectiveSize(); chain.Quorum.Threshold > size {
+		v.addf("%s.quorum.threshold: must not exceed size %d", path, size)
+	} else if chain.Quorum.Threshold > 0 && chain.Quorum.Threshold <= size/2 {
+		// Below a majority two different answers could both reach the threshold
+		v.addf("%s.quorum.threshold: must be a majority of size %d, at least %d", path, size, size/2+1)
+	}
+	v.circuitBreaker(path+".circuit_breaker", chain.CircuitBreaker)
+
+	if len(chain.Endpoints) == 0 {
+		v.addf("%s.endpoints: at least one endpoint is required", path)
+	}
+	names := make(map[string]bool, len(chain.Endpoints))
+	for i, e := range chain.Endpoints {
+		endpointPath := fmt.Sprintf("%s.endpoints[%d]", path, i)
+		if e.Name == "" {
+			v.addf("%s.name: is required", endpointPath)
+		} else {
+			endpointPath = fmt.Sprintf("%s.endpoints[%s]", path, e.Name)
+			if names[e.Name] {
+				v.addf("%s.name: is configured more than once", endpointPath)
+			}
+			names[e.Name] = true
+		}
+		v.endpoint(endpointPath, e)
+	}
+}
+
+// endpoint checks the settings of a single endpoint.
+func (v *validator) endpoint(path string, e EndpointConfig) {
+	if e.URL == "" {
+		v.addf("%s.url: is required", path)
+	} else if u, err := url.Parse(e.URL); err != nil || u.Host == "" || !validURLSchemes[strings.ToLower(u.Scheme)] {
+		// The URL itself is left out of the message as it may contain an API key
+		v.addf("%s.url: must be an absolute http, https, ws or wss URL", path)
+	}
+	if e.Timeout.Duration <= 0 {
+		v.addf("%s.timeout: must be positive", path)
+	}
+	if e.StaleTimeout.Duration < 0 {
+		v.addf("%s.stale_timeout: must not be negative", path)
+	}
+	if e.Weight < 0 {
+		v.addf("%s.weight: must not be negative", path)
+	}
+	if e.RateLimit < 0 || e.Burst < 0 {
+		v.addf("%s: rate_limit and burst must not be negative", path)
+	}
+	if e.Quota.Daily < 0 || e.Quota.Monthly < 0 {
+		v.addf("%s.quota: must not be negative", path)
+	}
+	if e.MaxBatchSize < 0 {
+		v.addf("%s.max_batch_size: must not be negative", path)
+	}
+	v.circuitBreaker(path+".circuit_breaker", e.CircuitBreaker)
+	if e.BasicAuth != nil && e.JWT != nil {
+		v.addf("%s: basic_auth and jwt cannot both be set", path)
+	}
+	if e.JWT != nil && !e.JWT.Secret.IsSet() {
+		v.addf("%s.jwt.secret: is required", path)
+	}
+}
+
+// circuitBreaker checks the thresholds of a chain or endpoint circuit breaker.
+func (v *validator) circuitBreaker(path string, c CircuitBreakerConfig) {
+	if c.FailureThreshold < 0 || c.MinRequests < 0 || c.HalfOpenMaxRequests < 0 {
+		v.addf("%s: failure_threshold, min_requests and half_open_max_requests must not be negative", path)
+	}
+	if c.FailureRate < 0 || c.FailureRate > 1 {
+		v.addf("%s.failure_rate: must be between 0 and 1", path)
+	}
+	if c.Window.Duration < 0 {
+		v.addf("%s.window: must not be negative", path)
+	}
+	if c.OpenDuration.Duration < 0 {
+		v.addf("%s.open_duration: must not be negative", path)
+	}
+}
+
+// listOr joins values for an error message, as in "a, b or c".
+func listOr(values []string) string {
+	if len(values) < 2 {
+		return strings.Join(values, "")
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
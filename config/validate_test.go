@@ -0,0 +1,67 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// validTestConfig returns a configuration that passes Validate.
+func validTestConfig() *Config {
+	c := Default()
+	c.Chains = []ChainConfig{{
+		Network: "mainnet",
+		ChainID: 1,
+		Endpoints: []EndpointConfig{{
+			Name:    "node",
+			URL:     "https://node.example.com",
+			Timeout: Duration{time.Second},
+		}},
+	}}
+	return c
+}
+
+func TestValidateChainSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string // part of the error, empty when valid
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"known strategy and policy", func(c *Config) {
+			c.Chains[0].PoolingStrategy = "least_latency"
+			c.Chains[0].RetryPolicy = "next"
+		}, ""},
+		{"unknown strategy", func(c *Config) { c.Chains[0].PoolingStrategy = "fastest" }, "chains[mainnet].pooling_strategy"},
+		{"unknown retry policy", func(c *Config) { c.Chains[0].RetryPolicy = "random" }, "chains[mainnet].retry_policy"},
+		{"chain failure rate", func(c *Config) { c.Chains[0].CircuitBreaker.FailureRate = 1.5 }, "chains[mainnet].circuit_breaker.failure_rate"},
+		{"endpoint breaker threshold", func(c *Config) {
+			c.Chains[0].Endpoints[0].CircuitBreaker.HalfOpenMaxRequests = -1
+		}, "chains[mainnet].endpoints[node].circuit_breaker"},
+		{"threshold within default size", func(c *Config) { c.Chains[0].Quorum.Threshold = 3 }, ""},
+		{"threshold above default size", func(c *Config) { c.Chains[0].Quorum.Threshold = 4 }, "chains[mainnet].quorum.threshold"},
+		{"threshold below majority", func(c *Config) {
+			c.Chains[0].Quorum = QuorumConfig{Size: 4, Threshold: 2}
+		}, "chains[mainnet].quorum.threshold: must be a majority"},
+		{"threshold below default majority", func(c *Config) { c.Chains[0].Quorum.Threshold = 1 }, "must be a majority"},
+		{"threshold above size", func(c *Config) {
+			c.Chains[0].Quorum = QuorumConfig{Size: 5, Threshold: 6}
+		}, "chains[mainnet].quorum.threshold"},
+		{"breaker open duration", func(c *Config) {
+			c.Chains[0].CircuitBreaker.OpenDuration = Duration{-time.Second}
+		}, "chains[mainnet].circuit_breaker.open_duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validTestConfig()
+			tt.modify(c)
+			err := c.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("Validate: %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Fatalf("Validate = %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce groups the burst of events an editor or deployment produces for one change
+const watchDebounce = 500 * time.Millisecond
+
+// kubernetesDataDir is the symlink Kubernetes swaps when a mounted ConfigMap changes
+const kubernetesDataDir = "..data"
+
+// Watch calls onChange after filename is written, created or replaced, until ctx is canceled.
+// The parent directory is watched rather than the file, so that editors that replace the
+// file and Kubernetes ConfigMap updates, which swap a symlink, are noticed as well.
+// Watcher errors are passed to onError.
+func Watch(ctx context.Context, filename string, onChange func(), onError func(error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating config watcher: %w", err)
+	}
+	dir, base := filepath.Split(filepath.Clean(filename))
+	if dir == "" {
+		dir = "."
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("error watching config directory: %w", err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Base(event.Name)
+				if name != base && name != kubernetesDataDir {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					debounce = time.After(watchDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				onError(err)
+			case <-debounce:
+				debounce = nil
+				onChange()
+			}
+		}
+	}()
+	return nil
+}
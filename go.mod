@@ -3,10 +3,13 @@ module github.com/pampatzoglou/chain-view
 go 1.23.2
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/go-redis/redis/v8 v8.11.5
+	github.com/gorilla/websocket v1.5.3
 	github.com/jackc/pgx/v4 v4.18.3
 	github.com/lib/pq v1.10.2
 	github.com/prometheus/client_golang v1.20.4
+	github.com/prometheus/common v0.55.0
 	github.com/sirupsen/logrus v1.9.3
 	golang.org/x/time v0.7.0
 	gopkg.in/yaml.v2 v2.4.0
@@ -25,9 +28,9 @@ require (
 	github.com/jackc/pgtype v1.14.0 // indirect
 	github.com/klauspost/compress v1.17.9 // indirect
 	github.com/kr/text v0.2.0 // indirect
+	github.com/kylelemons/godebug v1.1.0 // indirect
 	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
 	github.com/prometheus/client_model v0.6.1 // indirect
-	github.com/prometheus/common v0.55.0 // indirect
 	github.com/prometheus/procfs v0.15.1 // indirect
 	golang.org/x/crypto v0.20.0 // indirect
 	golang.org/x/sys v0.22.0 // indirect
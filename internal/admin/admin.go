@@ -0,0 +1,229 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pampatzoglou/chain-view/internal/endpoints"
+	"github.com/pampatzoglou/chain-view/internal/logging"
+)
+
+// maxRequestSize caps the size of an admin request body.
+const maxRequestSize = 1 << 10
+
+// callerHeaders carry the user authenticated by a reverse proxy in front of the admin API.
+var callerHeaders = []string{"X-Forwarded-User", "X-Forwarded-Email", "X-Remote-User"}
+
+// Auth controls who may call the admin API.
+type Auth struct {
+	// Token is required as a bearer token on every request when set.
+	Token string
+	// TrustProxyHeaders takes the caller recorded in audit logs from callerHeaders. Only
+	// enable it when every request passes an authenticating proxy that sets them.
+	TrustProxyHeaders bool
+}
+
+// Handler serves the admin API, which reports the state of the endpoint pools as JSON and
+// lets operators take endpoints out of rotation at runtime. Chains are addressed by
+// network name or chain ID, as in the proxy.
+type Handler struct {
+	mu     sync.RWMutex
+	pools  []*endpoints.EndpointPool
+	byID   map[string]*endpoints.EndpointPool
+	auth   Auth
+	logger *logging.Logger
+}
+
+// NewHandler creates an admin Handler for the given pools.
+func NewHandler(pools []*endpoints.EndpointPool, auth Auth, logger *logging.Logger) *Handler {
+	h := &Handler{auth: auth, logger: logger}
+	h.SetPools(pools)
+	return h
+}
+
+// Routes returns the admin API routes, behind the token check when a token is configured.
+// They are meant for their own listener, apart from the public JSON-RPC proxy.
+func (h *Handler) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/chains", h.ListChains)
+	mux.HandleFunc("GET /api/v1/chains/{id}/endpoints", h.ListEndpoints)
+	mux.HandleFunc("POST /api/v1/chains/{id}/endpoints/{name}/disable", h.DisableEndpoint)
+	mux.HandleFunc("POST /api/v1/chains/{id}/endpoints/{name}/drain", h.DrainEndpoint)
+	mux.HandleFunc("POST /api/v1/chains/{id}/endpoints/{name}/enable", h.EnableEndpoint)
+	mux.HandleFunc("POST /api/v1/chains/{id}/endpoints/{name}/circuit-breaker", h.ForceCircuitBreaker)
+	return h.authenticate(mux)
+}
+
+// authenticate rejects requests without the configured bearer token.
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	if h.auth.Token == "" {
+		return next
+	}
+	want := []byte(h.auth.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), want) != 1 {
+			h.logger.WithFields(logrus.Fields{
+				"path":   r.URL.Path,
+				"remote": r.RemoteAddr,
+			}).Warn("Rejected unauthenticated admin request")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetPools replaces the reported pools, for example after a configuration reload.
+func (h *Handler) SetPools(pools []*endpoints.EndpointPool) {
+	byID := make(map[string]*endpoints.EndpointPool, len(pools)*2)
+	for _, pool := range pools {
+		byID[pool.Network] = pool
+		byID[strconv.Itoa(pool.ChainID)] = pool
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pools = pools
+	h.byID = byID
+}
+
+// pool returns the pool of chain, looked up by network name or chain ID.
+func (h *Handler) pool(chain string) (*endpoints.EndpointPool, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	pool, ok := h.byID[chain]
+	return pool, ok
+}
+
+// ListChains handles GET /api/v1/chains and returns a summary of every pool.
+func (h *Handler) ListChains(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	pools := h.pools
+	h.mu.RUnlock()
+
+	chains := make([]endpoints.PoolStatus, 0, len(pools))
+	for _, pool := range pools {
+		chains = append(chains, pool.Status(false))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"chains": chains})
+}
+
+// ListEndpoints handles GET /api/v1/chains/{id}/endpoints and returns the pool of the
+// chain with the state of each of its endpoints.
+func (h *Handler) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	chain := r.PathValue("id")
+	pool, ok := h.pool(chain)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown chain: "+chain)
+		return
+	}
+	writeJSON(w, http.StatusOK, pool.Status(true))
+}
+
+// DisableEndpoint handles POST /api/v1/chains/{id}/endpoints/{name}/disable and takes the
+// endpoint out of rotation immediately.
+func (h *Handler) DisableEndpoint(w http.ResponseWriter, r *http.Request) {
+	h.setEndpointState(w, r, endpoints.AdminDisabled)
+}
+
+// DrainEndpoint handles POST /api/v1/chains/{id}/endpoints/{name}/drain and takes the
+// endpoint out of rotation once its in-flight requests have finished.
+func (h *Handler) DrainEndpoint(w http.ResponseWriter, r *http.Request) {
+	h.setEndpointState(w, r, endpoints.AdminDraining)
+}
+
+// EnableEndpoint handles POST /api/v1/chains/{id}/endpoints/{name}/enable and puts the
+// endpoint back into rotation.
+func (h *Handler) EnableEndpoint(w http.ResponseWriter, r *http.Request) {
+	h.setEndpointState(w, r, endpoints.AdminEnabled)
+}
+
+// setEndpointState changes the rotation state of the endpoint named in the path.
+func (h *Handler) setEndpointState(w http.ResponseWriter, r *http.Request, state string) {
+	pool, ok := h.requestPool(w, r)
+	if !ok {
+		return
+	}
+	status, err := pool.SetEndpointState(r.PathValue("name"), state, h.caller(r))
+	h.writeResult(w, r, status, err)
+}
+
+// ForceCircuitBreaker handles POST /api/v1/chains/{id}/endpoints/{name}/circuit-breaker.
+// The body selects the forced state: {"state": "open"} or {"state": "closed"}.
+func (h *Handler) ForceCircuitBreaker(w http.ResponseWriter, r *http.Request) {
+	pool, ok := h.requestPool(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	status, err := pool.ForceCircuitBreaker(r.PathValue("name"), req.State, h.caller(r))
+	h.writeResult(w, r, status, err)
+}
+
+// requestPool returns the pool of the chain in the path, writing a 404 when there is none.
+func (h *Handler) requestPool(w http.ResponseWriter, r *http.Request) (*endpoints.EndpointPool, bool) {
+	chain := r.PathValue("id")
+	pool, ok := h.pool(chain)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown chain: "+chain)
+	}
+	return pool, ok
+}
+
+// caller returns the identity of the client for audit logs: the user set by an
+// authenticating reverse proxy when those headers are trusted, else the remote address.
+func (h *Handler) caller(r *http.Request) string {
+	if h.auth.TrustProxyHeaders {
+		for _, header := range callerHeaders {
+			if user := r.Header.Get(header); user != "" {
+				return user
+			}
+		}
+	}
+	return r.RemoteAddr
+}
+
+// writeResult writes the endpoint status after an admin action, or the error it failed with.
+func (h *Handler) writeResult(w http.ResponseWriter, r *http.Request, status endpoints.EndpointStatus, err error) {
+	switch {
+	case errors.Is(err, endpoints.ErrEndpointNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"chain":    r.PathValue("id"),
+			"endpoint": r.PathValue("name"),
+			"actor":    h.caller(r),
+		}).Warn("Admin action rejected")
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, status)
+	}
+}
+
+// writeJSON writes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response with the given message.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pampatzoglou/chain-view/internal/logging"
+)
+
+func TestRoutesRequireToken(t *testing.T) {
+	h := NewHandler(nil, Auth{Token: "secret"}, logging.NewLogger("error"))
+	routes := h.Routes()
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		want          int
+	}{
+		{"read without token", http.MethodGet, "/api/v1/chains", "", http.StatusUnauthorized},
+		{"action without token", http.MethodPost, "/api/v1/chains/1/endpoints/a/disable", "", http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "/api/v1/chains/1/endpoints/a/disable", "Bearer other", http.StatusUnauthorized},
+		{"basic auth", http.MethodGet, "/api/v1/chains", "Basic c2VjcmV0Og==", http.StatusUnauthorized},
+		{"read with token", http.MethodGet, "/api/v1/chains", "Bearer secret", http.StatusOK},
+		{"action with token", http.MethodPost, "/api/v1/chains/1/endpoints/a/disable", "Bearer secret", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			routes.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallerTrustsProxyHeadersOnlyWhenEnabled(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-User", "alice")
+
+	untrusted := NewHandler(nil, Auth{}, logging.NewLogger("error"))
+	if got := untrusted.caller(req); got != "10.0.0.1:1234" {
+		t.Fatalf("caller = %q, want the remote address", got)
+	}
+	trusted := NewHandler(nil, Auth{TrustProxyHeaders: true}, logging.NewLogger("error"))
+	if got := trusted.caller(req); got != "alice" {
+		t.Fatalf("caller = %q, want alice", got)
+	}
+}
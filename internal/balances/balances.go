@@ -0,0 +1,226 @@
+package balances
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pampatzoglou/chain-view/internal/database"
+	"github.com/pampatzoglou/chain-view/internal/endpoints"
+	"github.com/pampatzoglou/chain-view/internal/logging"
+	"github.com/pampatzoglou/chain-view/internal/metrics"
+)
+
+const (
+	// headCheckInterval is how often the pool's head is checked when balances are read at
+	// every new block.
+	headCheckInterval = time.Second
+
+	// watchlistRefreshInterval is how often watched_addresses is read again.
+	watchlistRefreshInterval = time.Minute
+)
+
+// weiPerEther converts wei to the chain's native currency for the balance gauge.
+var weiPerEther = new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
+
+// store is the part of the database the Watcher reads watched addresses from and writes
+// balances to.
+type store interface {
+	WatchedAddresses(ctx context.Context, chainID int) ([]string, error)
+	LatestBalances(ctx context.Context, chainID int) (map[string]*big.Int, error)
+	StoreBalance(ctx context.Context, balance database.Balance) error
+}
+
+// Watcher tracks the balances of watched addresses on every chain, reading them through
+// the chain's endpoint pool and writing changes to the database.
+type Watcher struct {
+	db      store
+	logger  *logging.Logger
+	metrics *metrics.MetricsManager
+}
+
+// NewWatcher creates a Watcher that writes to db.
+func NewWatcher(db *database.DB, logger *logging.Logger, mm *metrics.MetricsManager) *Watcher {
+	return &Watcher{db: db, logger: logger, metrics: mm}
+}
+
+// chainState is the balance tracking state of one chain.
+type chainState struct {
+	pool      *endpoints.EndpointPool
+	last      map[string]*big.Int // last stored balance by address, nil until loaded
+	watched   []string            // addresses from watched_addresses
+	watchedAt time.Time
+	lastHead  uint64
+	exported  map[string]bool // addresses with a balance gauge
+}
+
+// Run reads the balance of every watched address of the pool's chain until ctx is
+// canceled: at every new block, or every poll_interval when that is set. Addresses come
+// from the chain configuration and from watched_addresses. A balance is written to the
+// balances table, and set as the address's current balance on the chain, only when it
+// changed.
+func (w *Watcher) Run(ctx context.Context, pool *endpoints.EndpointPool) {
+	state := &chainState{pool: pool, exported: make(map[string]bool)}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		next := headCheckInterval
+		settings := pool.BalanceSettings()
+		if settings.PollInterval.Duration > 0 {
+			next = settings.PollInterval.Duration
+		}
+		if err := w.poll(ctx, state, settings.Addresses, settings.PollInterval.Duration > 0); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			w.logger.WithError(err).WithFields(logrus.Fields{
+				"network":  pool.Network,
+				"chain_id": pool.ChainID,
+			}).Warn("Failed to update watched address balances")
+		}
+		timer.Reset(next)
+	}
+}
+
+// poll reads and stores the balances of the watched addresses. Without an interval it only
+// does so when the pool has seen a new block since the last poll, so balances that failed
+// are read again at the next block. When watched_addresses cannot be read, the addresses
+// loaded before are kept.
+func (w *Watcher) poll(ctx context.Context, state *chainState, configured []string, interval bool) error {
+	pool := state.pool
+
+	var errs []error
+	if state.watchedAt.IsZero() || time.Since(state.watchedAt) >= watchlistRefreshInterval {
+		state.watchedAt = time.Now()
+		watched, err := w.db.WatchedAddresses(ctx, pool.ChainID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load watched addresses: %w", err))
+		} else {
+			state.watched = watched
+		}
+	}
+
+	addresses := watchlist(configured, state.watched)
+	w.retainGauges(state, addresses)
+	if len(addresses) == 0 {
+		return errors.Join(errs...)
+	}
+
+	head := pool.BestHead()
+	if !interval && (head == 0 || head == state.lastHead) {
+		return errors.Join(errs...)
+	}
+	state.lastHead = head
+
+	if state.last == nil {
+		last, err := w.db.LatestBalances(ctx, pool.ChainID)
+		if err != nil {
+			return errors.Join(append(errs, fmt.Errorf("failed to load stored balances: %w", err))...)
+		}
+		state.last = last
+	}
+
+	for _, address := range addresses {
+		if err := w.update(ctx, state, address); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.metrics.BalanceErrors.WithLabelValues(pool.Network).Inc()
+			errs = append(errs, fmt.Errorf("%s: %w", address, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// update reads the balance of an address and stores it when it changed.
+func (w *Watcher) update(ctx context.Context, state *chainState, address string) error {
+	pool := state.pool
+
+	var balanceHex string
+	if err := pool.Call(ctx, "eth_getBalance", []interface{}{address, "latest"}, &balanceHex); err != nil {
+		return fmt.Errorf("failed to get balance: %w", err)
+	}
+	if !strings.HasPrefix(balanceHex, "0x") && !strings.HasPrefix(balanceHex, "0X") {
+		return fmt.Errorf("invalid balance %q", balanceHex)
+	}
+	wei, ok := new(big.Int).SetString(balanceHex[2:], 16)
+	if !ok {
+		return fmt.Errorf("invalid balance %q", balanceHex)
+	}
+
+	ether, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), weiPerEther).Float64()
+	w.metrics.AddressBalance.WithLabelValues(pool.Network, address).Set(ether)
+	state.exported[address] = true
+
+	previous, known := state.last[address]
+	if known && previous.Cmp(wei) == 0 {
+		return nil
+	}
+	if err := w.db.StoreBalance(ctx, database.Balance{
+		ChainID: pool.ChainID,
+		Address: address,
+		Wei:     wei,
+		At:      time.Now(),
+	}); err != nil {
+		return err
+	}
+	state.last[address] = wei
+	w.metrics.BalanceChanges.WithLabelValues(pool.Network).Inc()
+
+	fields := logrus.Fields{
+		"network": pool.Network,
+		"address": address,
+		"balance": wei.String(),
+	}
+	if known {
+		fields["previous"] = previous.String()
+	}
+	w.logger.WithFields(fields).Info("Watched address balance changed")
+	return nil
+}
+
+// retainGauges removes the balance gauges of addresses that are no longer watched.
+func (w *Watcher) retainGauges(state *chainState, addresses []string) {
+	watched := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		watched[address] = true
+	}
+	for address := range state.exported {
+		if !watched[address] {
+			w.metrics.AddressBalance.DeleteLabelValues(state.pool.Network, address)
+			delete(state.exported, address)
+		}
+	}
+}
+
+// watchlist merges the configured and stored addresses into a sorted list of distinct
+// lower case addresses.
+func watchlist(configured, stored []string) []string {
+	seen := make(map[string]bool, len(configured)+len(stored))
+	var addresses []string
+	for _, list := range [][]string{configured, stored} {
+		for _, address := range list {
+			address = strings.ToLower(address)
+			if !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
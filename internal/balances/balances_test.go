@@ -0,0 +1,126 @@
+package balances
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/pampatzoglou/chain-view/config"
+	"github.com/pampatzoglou/chain-view/internal/database"
+	"github.com/pampatzoglou/chain-view/internal/endpoints"
+	"github.com/pampatzoglou/chain-view/internal/logging"
+	"github.com/pampatzoglou/chain-view/internal/metrics"
+)
+
+// fakeStore keeps watched addresses and stored balances in memory.
+type fakeStore struct {
+	watched []string
+	latest  map[string]*big.Int
+	stored  []database.Balance
+}
+
+func (s *fakeStore) WatchedAddresses(ctx context.Context, chainID int) ([]string, error) {
+	return s.watched, nil
+}
+
+func (s *fakeStore) LatestBalances(ctx context.Context, chainID int) (map[string]*big.Int, error) {
+	latest := make(map[string]*big.Int, len(s.latest))
+	for address, wei := range s.latest {
+		latest[address] = wei
+	}
+	return latest, nil
+}
+
+func (s *fakeStore) StoreBalance(ctx context.Context, balance database.Balance) error {
+	s.stored = append(s.stored, balance)
+	return nil
+}
+
+func TestWatchlist(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured []string
+		stored     []string
+		want       []string
+	}{
+		{name: "empty"},
+		{name: "configured only", configured: []string{"0xB", "0xa"}, want: []string{"0xa", "0xb"}},
+		{name: "stored only", stored: []string{"0xc"}, want: []string{"0xc"}},
+		{name: "merged", configured: []string{"0xb"}, stored: []string{"0xa"}, want: []string{"0xa", "0xb"}},
+		{name: "duplicates in any case", configured: []string{"0xAB", "0xab"}, stored: []string{"0xAb"}, want: []string{"0xab"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := watchlist(tt.configured, tt.stored); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("watchlist = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollStoresOnlyChangedBalances(t *testing.T) {
+	var balance atomic.Value
+	balance.Store("0x10")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + balance.Load().(string) + `"}`))
+	}))
+	defer server.Close()
+
+	logger := logging.NewLogger("error")
+	mm := metrics.NewMetricsManager(logger, prometheus.NewRegistry())
+	pool, err := endpoints.NewEndpointPool(config.ChainConfig{
+		Network:             "test",
+		ChainID:             1,
+		BackgroundRateLimit: 1000,
+		Endpoints: []config.EndpointConfig{{
+			Name:      "provider",
+			URL:       server.URL,
+			Timeout:   config.Duration{Duration: time.Second},
+			RateLimit: 1000,
+		}},
+	}, logger, mm)
+	if err != nil {
+		t.Fatalf("NewEndpointPool: %v", err)
+	}
+
+	// 0xa is stored with the balance it still has, 0xb is new
+	db := &fakeStore{watched: []string{"0xB"}, latest: map[string]*big.Int{"0xa": big.NewInt(16)}}
+	w := &Watcher{db: db, logger: logger, metrics: mm}
+	state := &chainState{pool: pool, exported: make(map[string]bool)}
+	poll := func() {
+		t.Helper()
+		if err := w.poll(context.Background(), state, []string{"0xA"}, true); err != nil {
+			t.Fatalf("poll: %v", err)
+		}
+	}
+
+	poll()
+	if len(db.stored) != 1 || db.stored[0].Address != "0xb" || db.stored[0].Wei.Int64() != 16 {
+		t.Fatalf("stored %+v, want only the first balance of 0xb", db.stored)
+	}
+
+	poll()
+	if len(db.stored) != 1 {
+		t.Fatalf("%d balances stored, want no rows for unchanged balances", len(db.stored))
+	}
+
+	balance.Store("0x20")
+	poll()
+	if len(db.stored) != 3 {
+		t.Fatalf("%d balances stored, want a row for both changed balances", len(db.stored))
+	}
+	if changes := testutil.ToFloat64(mm.BalanceChanges.WithLabelValues("test")); changes != 3 {
+		t.Fatalf("balance changes = %v, want 3", changes)
+	}
+	if ether := testutil.ToFloat64(mm.AddressBalance.WithLabelValues("test", "0xa")); ether != 32e-18 {
+		t.Fatalf("balance gauge of 0xa = %v, want 32 wei in ether", ether)
+	}
+}
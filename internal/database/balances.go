@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Balance is the balance of an address on a chain, in wei, as read at a point in time.
+type Balance struct {
+	ChainID int
+	Address string
+	Wei     *big.Int
+	At      time.Time
+}
+
+// WatchedAddresses returns the addresses in watched_addresses for a chain, in lower case.
+func (db *DB) WatchedAddresses(ctx context.Context, chainID int) ([]string, error) {
+	var addresses []string
+	err := db.observe("load_watched_addresses", func() error {
+		rows, err := db.Conn.QueryContext(ctx,
+			"SELECT LOWER(address) FROM watched_addresses WHERE chain_id = $1 ORDER BY address", chainID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var address string
+			if err := rows.Scan(&address); err != nil {
+				return err
+			}
+			addresses = append(addresses, address)
+		}
+		return rows.Err()
+	})
+	return addresses, err
+}
+
+// LatestBalances returns the last stored balance in wei of every address on a chain.
+func (db *DB) LatestBalances(ctx context.Context, chainID int) (map[string]*big.Int, error) {
+	balances := make(map[string]*big.Int)
+	err := db.observe("load_latest_balances", func() error {
+		rows, err := db.Conn.QueryContext(ctx, `SELECT DISTINCT ON (a.address)
+    a.address,
+    (b.balance * 1000000000000000000)::NUMERIC(78, 0)::TEXT
+FROM balances b
+JOIN addresses a ON a.id = b.address_id
+WHERE b.chain_id = $1
+ORDER BY a.address, b.timestamp DESC, b.id DESC`, chainID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var address, wei string
+			if err := rows.Scan(&address, &wei); err != nil {
+				return err
+			}
+			v, ok := new(big.Int).SetString(wei, 10)
+			if !ok {
+				return fmt.Errorf("invalid balance %q of %s", wei, address)
+			}
+			balances[address] = v
+		}
+		return rows.Err()
+	})
+	return balances, err
+}
+
+// StoreBalance records a changed balance in balances and sets it as the current balance
+// of the address on the balance's chain, creating the address if needed. The balance is
+// also written to addresses.current_balance, which holds the last change on any chain.
+func (db *DB) StoreBalance(ctx context.Context, balance Balance) error {
+	return db.inTx(ctx, "store_balance", func(tx *sql.Tx) error {
+		amount := weiToEther(balance.Wei)
+
+		var addressID int
+		if err := tx.QueryRowContext(ctx, `INSERT INTO addresses (address, current_balance, updated_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (address) DO UPDATE SET
+    current_balance = EXCLUDED.current_balance,
+    updated_at = NOW()
+RETURNING id`, balance.Address, amount).Scan(&addressID); err != nil {
+			return fmt.Errorf("failed to update address: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO address_current_balances (address_id, chain_id, current_balance, updated_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (chain_id, address_id) DO UPDATE SET
+    current_balance = EXCLUDED.current_balance,
+    updated_at = NOW()`, addressID, balance.ChainID, amount); err != nil {
+			return fmt.Errorf("failed to update current balance: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO balances (address_id, chain_id, balance, timestamp) VALUES ($1, $2, $3, $4)",
+			addressID, balance.ChainID, amount, balance.At.UTC(),
+		); err != nil {
+			return fmt.Errorf("failed to store balance: %w", err)
+		}
+		return nil
+	})
+}
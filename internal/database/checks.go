@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Endpoint check statuses stored in endpoint_checks.status.
+const (
+	CheckStatusUp      = "up"
+	CheckStatusDown    = "down"
+	CheckStatusSkipped = "skipped" // not sent because of local limits; not downtime
+)
+
+// Batching of endpoint check writes.
+const (
+	checkBufferSize    = 10000           // checks queued before new ones are dropped
+	checkBatchSize     = 500             // checks written per INSERT
+	checkFlushInterval = 5 * time.Second // longest time a check waits in the buffer
+	checkWriteTimeout  = 30 * time.Second
+)
+
+// endpointCheckColumns are the columns written for every check, in insert order.
+var endpointCheckColumns = []string{
+	"checked_at", "chain", "chain_id", "endpoint", "status",
+	"http_code", "latency_ms", "block_height", "error_class",
+}
+
+// EndpointCheck is the result of a single probe of an endpoint, stored in endpoint_checks.
+// Zero HTTPCode, BlockHeight and ErrorClass are stored as NULL.
+type EndpointCheck struct {
+	CheckedAt   time.Time
+	Chain       string
+	ChainID     int
+	Endpoint    string
+	Status      string // up, down or skipped
+	HTTPCode    int
+	Latency     time.Duration
+	BlockHeight uint64
+	ErrorClass  string
+}
+
+// CheckWriter writes endpoint checks to the database in batches, off the probing path.
+// Record never blocks: when the buffer is full the check is dropped and counted.
+type CheckWriter struct {
+	db     *DB
+	checks chan EndpointCheck
+	done   chan struct{}
+}
+
+// NewCheckWriter creates a CheckWriter. Checks are written once Run is started.
+func NewCheckWriter(db *DB) *CheckWriter {
+	return &CheckWriter{
+		db:     db,
+		checks: make(chan EndpointCheck, checkBufferSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Record queues a check for writing. It is safe to call on a nil CheckWriter, which
+// discards the check, so probing works without a database.
+func (w *CheckWriter) Record(check EndpointCheck) {
+	if w == nil {
+		return
+	}
+	select {
+	case w.checks <- check:
+	default:
+		w.db.metrics.EndpointChecksDropped.Inc()
+	}
+}
+
+// Run writes queued checks every checkFlushInterval or whenever a full batch is ready,
+// until ctx is canceled. Checks still queued then are written before it returns.
+func (w *CheckWriter) Run(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(checkFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]EndpointCheck, 0, checkBatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			w.write(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case check := <-w.checks:
+					batch = append(batch, check)
+					if len(batch) == checkBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		case check := <-w.checks:
+			batch = append(batch, check)
+			if len(batch) == checkBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Wait blocks until Run has written the remaining checks and returned.
+func (w *CheckWriter) Wait() {
+	if w != nil {
+		<-w.done
+	}
+}
+
+// write inserts a batch with a single multi-row INSERT. A failed batch is dropped and
+// counted rather than retried, so a database outage cannot make the buffer grow without bound.
+func (w *CheckWriter) write(batch []EndpointCheck) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkWriteTimeout)
+	defer cancel()
+
+	query, args := insertChecksQuery(batch)
+	if err := w.db.Exec(ctx, "insert_endpoint_checks", query, args...); err != nil {
+		w.db.metrics.EndpointChecksDropped.Add(float64(len(batch)))
+		w.db.Logger.WithError(err).WithFields(logrus.Fields{
+			"checks": len(batch),
+		}).Error("Failed to write endpoint checks")
+		return
+	}
+	w.db.metrics.EndpointChecksWritten.Add(float64(len(batch)))
+}
+
+// insertChecksQuery builds the INSERT statement and its arguments for a batch.
+func insertChecksQuery(batch []EndpointCheck) (string, []interface{}) {
+	var b strings.Builder
+	b.WriteString("INSERT INTO endpoint_checks (")
+	b.WriteString(strings.Join(endpointCheckColumns, ", "))
+	b.WriteString(") VALUES ")
+	writePlaceholders(&b, len(batch), len(endpointCheckColumns))
+
+	args := make([]interface{}, 0, len(batch)*len(endpointCheckColumns))
+	for _, c := range batch {
+		args = append(args,
+			c.CheckedAt.UTC(),
+			c.Chain,
+			c.ChainID,
+			c.Endpoint,
+			c.Status,
+			nullIfZero(int64(c.HTTPCode)),
+			float64(c.Latency)/float64(time.Millisecond),
+			nullIfZero(int64(c.BlockHeight)),
+			nullIfEmpty(c.ErrorClass),
+		)
+	}
+	return b.String(), args
+}
+
+// nullIfZero returns nil for zero, which is written as NULL.
+func nullIfZero(v int64) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+// nullIfEmpty returns nil for an empty string, which is written as NULL.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
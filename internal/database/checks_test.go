@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/pampatzoglou/chain-view/internal/logging"
+	"github.com/pampatzoglou/chain-view/internal/metrics"
+)
+
+// recordingDriver is a database/sql driver that records the number of rows of every
+// INSERT it executes instead of talking to a database.
+type recordingDriver struct {
+	mu   sync.Mutex
+	rows []int
+	err  error
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *recordingConn) Close() error                        { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if c.d.err != nil {
+		return nil, c.d.err
+	}
+	c.d.rows = append(c.d.rows, len(args)/len(endpointCheckColumns))
+	return driver.RowsAffected(len(args) / len(endpointCheckColumns)), nil
+}
+
+// recordingConnector opens connections of a recordingDriver.
+type recordingConnector struct{ d *recordingDriver }
+
+func (c recordingConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c recordingConnector) Driver() driver.Driver                        { return c.d }
+
+// newRecordingDB returns a DB backed by a recordingDriver, with metrics on a private registry.
+func newRecordingDB(t *testing.T) (*DB, *recordingDriver) {
+	t.Helper()
+	d := &recordingDriver{}
+	conn := sql.OpenDB(recordingConnector{d})
+	t.Cleanup(func() { conn.Close() })
+	logger := logging.NewLogger("error")
+	return &DB{Conn: conn, Logger: logger, metrics: metrics.NewMetricsManager(logger, prometheus.NewRegistry())}, d
+}
+
+func TestInsertChecksQuery(t *testing.T) {
+	at := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	query, args := insertChecksQuery([]EndpointCheck{
+		{CheckedAt: at, Chain: "mainnet", ChainID: 1, Endpoint: "a", Status: CheckStatusUp, HTTPCode: 200, Latency: 1500 * time.Microsecond, BlockHeight: 100},
+		{CheckedAt: at, Chain: "mainnet", ChainID: 1, Endpoint: "b", Status: CheckStatusDown, ErrorClass: "timeout"},
+	})
+
+	wantQuery := "INSERT INTO endpoint_checks (checked_at, chain, chain_id, endpoint, status, http_code, latency_ms, block_height, error_class) VALUES " +
+		"($1, $2, $3, $4, $5, $6, $7, $8, $9), ($10, $11, $12, $13, $14, $15, $16, $17, $18)"
+	if query != wantQuery {
+		t.Fatalf("query = %s\nwant %s", query, wantQuery)
+	}
+	if len(args) != 18 {
+		t.Fatalf("%d arguments, want 18", len(args))
+	}
+	if args[0] != at.UTC() {
+		t.Errorf("checked_at = %v, want it in UTC", args[0])
+	}
+	if args[6] != 1.5 {
+		t.Errorf("latency_ms = %v, want 1.5", args[6])
+	}
+	for i, name := range map[int]string{8: "error_class of a", 14: "http_code of b", 16: "block_height of b"} {
+		if args[i] != nil {
+			t.Errorf("%s = %v, want NULL", name, args[i])
+		}
+	}
+	if args[17] != "timeout" {
+		t.Errorf("error_class of b = %v, want timeout", args[17])
+	}
+}
+
+func TestCheckWriterWritesInBatches(t *testing.T) {
+	db, d := newRecordingDB(t)
+	w := NewCheckWriter(db)
+	for i := 0; i < 2*checkBatchSize+200; i++ {
+		w.Record(EndpointCheck{Chain: "mainnet", Endpoint: "a", Status: CheckStatusUp})
+	}
+
+	// Canceling Run writes what is still queued
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.Run(ctx)
+	w.Wait()
+
+	if want := []int{checkBatchSize, checkBatchSize, 200}; !reflect.DeepEqual(d.rows, want) {
+		t.Fatalf("rows per INSERT = %v, want %v", d.rows, want)
+	}
+	if n := testutil.ToFloat64(db.metrics.EndpointChecksWritten); n != 1200 {
+		t.Fatalf("checks written = %v, want 1200", n)
+	}
+}
+
+func TestCheckWriterDropsChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		record      int
+		writeErr    error
+		wantWritten float64
+		wantDropped float64
+	}{
+		{name: "written", record: 10, wantWritten: 10},
+		{name: "buffer full", record: checkBufferSize + 3, wantWritten: checkBufferSize, wantDropped: 3},
+		{name: "failed write", record: 10, writeErr: errors.New("connection refused"), wantDropped: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, d := newRecordingDB(t)
+			d.err = tt.writeErr
+			w := NewCheckWriter(db)
+			for i := 0; i < tt.record; i++ {
+				w.Record(EndpointCheck{Chain: "mainnet", Endpoint: "a", Status: CheckStatusDown})
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			w.Run(ctx)
+
+			if n := testutil.ToFloat64(db.metrics.EndpointChecksWritten); n != tt.wantWritten {
+				t.Errorf("checks written = %v, want %v", n, tt.wantWritten)
+			}
+			if n := testutil.ToFloat64(db.metrics.EndpointChecksDropped); n != tt.wantDropped {
+				t.Errorf("checks dropped = %v, want %v", n, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestNilCheckWriter(t *testing.T) {
+	var w *CheckWriter
+	w.Record(EndpointCheck{Status: CheckStatusUp})
+	w.Wait()
+}
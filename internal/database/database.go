@@ -1,101 +1,88 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/pampatzoglou/chain-view/internal/logging"
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/pampatzoglou/chain-view/internal/metrics"
+	"github.com/pampatzoglou/chain-view/internal/redact"
 	"github.com/sirupsen/logrus"
 )
 
-var (
-	dbQueryCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "db_queries_total",
-			Help: "Total number of database queries",
-		},
-		[]string{"query"},
-	)
-
-	dbQueryDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "db_query_duration_seconds",
-			Help:    "Duration of database queries",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"query"},
-	)
-)
-
 // DB wraps the SQL database object and logger
 type DB struct {
-	Conn   *sql.DB
-	Logger *logging.Logger
+	Conn    *sql.DB
+	Logger  *logging.Logger
+	metrics *metrics.MetricsManager
 }
 
-// Initialize creates a database connection and registers Prometheus metrics
-func Initialize(url string, logger *logging.Logger) (*DB, error) {
+// Initialize creates a database connection. Queries are counted in the database collectors of mm.
+func Initialize(url string, logger *logging.Logger, mm *metrics.MetricsManager) (*DB, error) {
 	logger.WithFields(logrus.Fields{
-		"url": url,
+		"url": redact.URL(url),
 	}).Info("Initializing database connection")
 
 	db, err := sql.Open("pgx", url)
 	if err != nil {
 		logger.WithFields(logrus.Fields{
-			"error": err,
-			"url":   url,
+			"error": redact.Error(err),
+			"url":   redact.URL(url),
 		}).Error("Error connecting to the database")
 		return nil, err
 	}
 
-	// Register Prometheus metrics
-	prometheus.MustRegister(dbQueryCounter)
-	prometheus.MustRegister(dbQueryDuration)
-
 	// Ping the database to ensure the connection is valid
 	if err := db.Ping(); err != nil {
 		logger.WithFields(logrus.Fields{
 			"error": err,
-			"url":   url,
+			"url":   redact.URL(url),
 		}).Error("Database connection failed")
+		db.Close()
 		return nil, err
 	}
 
 	logger.Info("Connected to the database successfully")
-	return &DB{Conn: db, Logger: logger}, nil
+	return &DB{Conn: db, Logger: logger, metrics: mm}, nil
 }
 
 // PerformQuery executes a query and updates Prometheus metrics
 func (db *DB) PerformQuery(query string) error {
+	return db.Exec(context.Background(), query, query)
+}
+
+// Exec executes a query with arguments and updates Prometheus metrics. The query is
+// logged and labelled by name, so statements with many parameters stay readable.
+func (db *DB) Exec(ctx context.Context, name, query string, args ...interface{}) error {
 	db.Logger.WithFields(logrus.Fields{
-		"query": query,
+		"query": name,
 	}).Debug("Executing database query")
 
 	start := time.Now()
 
 	// Increment query counter
-	dbQueryCounter.WithLabelValues(query).Inc()
+	db.metrics.DBQueries.WithLabelValues(name).Inc()
 
 	// Execute the query
-	_, err := db.Conn.Exec(query)
+	_, err := db.Conn.ExecContext(ctx, query, args...)
 
 	// Record query duration
 	duration := time.Since(start).Seconds()
-	dbQueryDuration.WithLabelValues(query).Observe(duration)
+	db.metrics.DBQueryDuration.WithLabelValues(name).Observe(duration)
 
 	if err != nil {
 		db.Logger.WithFields(logrus.Fields{
 			"error":    err,
-			"query":    query,
+			"query":    name,
 			"duration": duration,
 		}).Error("Database query failed")
 		return err
 	}
 
 	db.Logger.WithFields(logrus.Fields{
-		"query":    query,
+		"query":    name,
 		"duration": duration,
 	}).Debug("Database query executed successfully")
 
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ingestBatchSize caps the rows written per INSERT, keeping large blocks well below the
+// PostgreSQL limit of 65535 parameters per statement.
+const ingestBatchSize = 1000
+
+// weiPerEther scales wei amounts to the 18 decimals of the NUMERIC(38, 18) columns.
+var weiPerEther = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+// transactionColumns are the columns written for every transaction, in insert order.
+var transactionColumns = []string{
+	"chain_id", "transaction_hash", "from_address", "to_address", "value",
+	"gas_price", "gas_used", "block_number", "timestamp",
+}
+
+// IngestCursor is the last block stored by ingestion for a chain.
+type IngestCursor struct {
+	BlockNumber uint64
+	BlockHash   string
+}
+
+// Block is an ingested block with its transactions.
+type Block struct {
+	ChainID      int
+	Network      string
+	Number       uint64
+	Hash         string
+	Timestamp    time.Time
+	Transactions []Transaction
+}
+
+// Transaction is an ingested transaction. Amounts are in wei and stored in ether.
+type Transaction struct {
+	Hash     string
+	From     string
+	To       string   // empty for contract creations, stored as NULL
+	Value    *big.Int // wei
+	GasPrice *big.Int // effective gas price in wei
+	GasUsed  uint64
+}
+
+// LoadCursor returns the ingestion cursor of a chain, and false when the chain has none yet.
+func (db *DB) LoadCursor(ctx context.Context, chainID int) (IngestCursor, bool, error) {
+	var cursor IngestCursor
+	err := db.observe("load_ingestion_cursor", func() error {
+		return db.Conn.QueryRowContext(ctx,
+			"SELECT block_number, block_hash FROM ingestion_cursors WHERE chain_id = $1", chainID,
+		).Scan(&cursor.BlockNumber, &cursor.BlockHash)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return IngestCursor{}, false, nil
+	}
+	if err != nil {
+		return IngestCursor{}, false, err
+	}
+	return cursor, true, nil
+}
+
+// StoreBlock upserts the addresses and transactions of a block and moves the cursor of its
+// chain to the block, in one transaction. Storing a block again updates its transactions.
+func (db *DB) StoreBlock(ctx context.Context, block Block) error {
+	return db.inTx(ctx, "store_block", func(tx *sql.Tx) error {
+		if err := upsertAddresses(ctx, tx, blockAddresses(block.Transactions)); err != nil {
+			return fmt.Errorf("failed to store addresses: %w", err)
+		}
+		for start := 0; start < len(block.Transactions); start += ingestBatchSize {
+			end := min(start+ingestBatchSize, len(block.Transactions))
+			query, args := upsertTransactionsQuery(block, block.Transactions[start:end])
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return fmt.Errorf("failed to store transactions: %w", err)
+			}
+		}
+		return storeCursor(ctx, tx, block.ChainID, block.Network, IngestCursor{BlockNumber: block.Number, BlockHash: block.Hash})
+	})
+}
+
+// RewindBlock removes the transactions of a block that left the canonical chain and moves
+// the cursor back to its parent.
+func (db *DB) RewindBlock(ctx context.Context, chainID int, network string, number uint64, parent IngestCursor) error {
+	return db.inTx(ctx, "rewind_block", func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM transactions WHERE chain_id = $1 AND block_number >= $2", chainID, number,
+		); err != nil {
+			return fmt.Errorf("failed to remove transactions: %w", err)
+		}
+		return storeCursor(ctx, tx, chainID, network, parent)
+	})
+}
+
+// observe runs a query and records it in the database metrics under name.
+func (db *DB) observe(name string, query func() error) error {
+	start := time.Now()
+	db.metrics.DBQueries.WithLabelValues(name).Inc()
+	err := query()
+	db.metrics.DBQueryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// inTx runs fn in a transaction that is committed when fn succeeds and rolled back otherwise.
+func (db *DB) inTx(ctx context.Context, name string, fn func(tx *sql.Tx) error) error {
+	return db.observe(name, func() error {
+		tx, err := db.Conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		if err := fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// storeCursor sets the ingestion cursor of a chain.
+func storeCursor(ctx context.Context, tx *sql.Tx, chainID int, network string, cursor IngestCursor) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO ingestion_cursors (chain_id, network, block_number, block_hash, updated_at)
+VALUES ($1, $2, $3, $4, NOW())
+ON CONFLICT (chain_id) DO UPDATE SET
+    network = EXCLUDED.network,
+    block_number = EXCLUDED.block_number,
+    block_hash = EXCLUDED.block_hash,
+    updated_at = NOW()`,
+		chainID, network, int64(cursor.BlockNumber), cursor.BlockHash)
+	if err != nil {
+		return fmt.Errorf("failed to store ingestion cursor: %w", err)
+	}
+	return nil
+}
+
+// blockAddresses returns the distinct senders and recipients of txs.
+func blockAddresses(txs []Transaction) []string {
+	seen := make(map[string]bool, len(txs)*2)
+	var addresses []string
+	for _, t := range txs {
+		for _, address := range []string{t.From, t.To} {
+			if address != "" && !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses
+}
+
+// upsertAddresses inserts the addresses that are not stored yet.
+func upsertAddresses(ctx context.Context, tx *sql.Tx, addresses []string) error {
+	for start := 0; start < len(addresses); start += ingestBatchSize {
+		batch := addresses[start:min(start+ingestBatchSize, len(addresses))]
+
+		var b strings.Builder
+		b.WriteString("INSERT INTO addresses (address) VALUES ")
+		writePlaceholders(&b, len(batch), 1)
+		b.WriteString(" ON CONFLICT (address) DO NOTHING")
+
+		args := make([]interface{}, len(batch))
+		for i, address := range batch {
+			args[i] = address
+		}
+		if _, err := tx.ExecContext(ctx, b.String(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upsertTransactionsQuery builds the upsert statement and its arguments for transactions of block.
+func upsertTransactionsQuery(block Block, txs []Transaction) (string, []interface{}) {
+	var b strings.Builder
+	b.WriteString("INSERT INTO transactions (")
+	b.WriteString(strings.Join(transactionColumns, ", "))
+	b.WriteString(") VALUES ")
+	writePlaceholders(&b, len(txs), len(transactionColumns))
+	b.WriteString(` ON CONFLICT (chain_id, transaction_hash) DO UPDATE SET
+    from_address = EXCLUDED.from_address,
+    to_address = EXCLUDED.to_address,
+    value = EXCLUDED.value,
+    gas_price = EXCLUDED.gas_price,
+    gas_used = EXCLUDED.gas_used,
+    block_number = EXCLUDED.block_number,
+    timestamp = EXCLUDED.timestamp,
+    updated_at = NOW()`)
+
+	args := make([]interface{}, 0, len(txs)*len(transactionColumns))
+	for _, t := range txs {
+		args = append(args,
+			block.ChainID,
+			t.Hash,
+			t.From,
+			nullIfEmpty(t.To),
+			weiToEther(t.Value),
+			weiToEther(t.GasPrice),
+			int64(t.GasUsed),
+			int64(block.Number),
+			block.Timestamp.UTC(),
+		)
+	}
+	return b.String(), args
+}
+
+// writePlaceholders writes rows groups of cols numbered parameters, such as ($1, $2), ($3, $4).
+func writePlaceholders(b *strings.Builder, rows, cols int) {
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for j := 0; j < cols; j++ {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(b, "$%d", i*cols+j+1)
+		}
+		b.WriteString(")")
+	}
+}
+
+// weiToEther formats a wei amount as a decimal ether amount with 18 decimals. nil is zero.
+func weiToEther(wei *big.Int) string {
+	if wei == nil {
+		return "0"
+	}
+	whole, frac := new(big.Int).QuoRem(wei, weiPerEther, new(big.Int))
+	return fmt.Sprintf("%s.%018s", whole, frac)
+}
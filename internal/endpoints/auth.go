@@ -0,0 +1,120 @@
+package endpoints
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+// jwtSecretLength is the size of an HS256 secret as used by the execution engine API.
+const jwtSecretLength = 32
+
+// endpointAuth holds the resolved credentials of an endpoint.
+type endpointAuth struct {
+	headers   http.Header
+	username  string
+	password  string
+	basic     bool
+	jwtSecret []byte
+	jwtID     string
+}
+
+// newEndpointAuth resolves the headers, basic auth and JWT settings of an endpoint.
+// It returns nil when the endpoint configures no authentication.
+func newEndpointAuth(e config.EndpointConfig) (*endpointAuth, error) {
+	if len(e.Headers) == 0 && e.BasicAuth == nil && e.JWT == nil {
+		return nil, nil
+	}
+	if e.BasicAuth != nil && e.JWT != nil {
+		return nil, fmt.Errorf("basic_auth and jwt cannot both be set")
+	}
+
+	auth := &endpointAuth{headers: make(http.Header, len(e.Headers))}
+	for name, secret := range e.Headers {
+		value, err := secret.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		auth.headers.Set(name, value)
+	}
+
+	if e.BasicAuth != nil {
+		username, err := e.BasicAuth.Username.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("basic auth username: %w", err)
+		}
+		password, err := e.BasicAuth.Password.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("basic auth password: %w", err)
+		}
+		auth.username, auth.password, auth.basic = username, password, true
+	}
+
+	if e.JWT != nil {
+		raw, err := e.JWT.Secret.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("jwt secret: %w", err)
+		}
+		secret, err := hex.DecodeString(strings.TrimPrefix(raw, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("jwt secret must be hex encoded: %w", err)
+		}
+		if len(secret) != jwtSecretLength {
+			return nil, fmt.Errorf("jwt secret must be %d bytes, got %d", jwtSecretLength, len(secret))
+		}
+		auth.jwtSecret, auth.jwtID = secret, e.JWT.ID
+	}
+	return auth, nil
+}
+
+// header returns the headers to send with a request. JWT tokens are signed on every
+// call because providers reject tokens whose iat is more than a minute off.
+func (a *endpointAuth) header() (http.Header, error) {
+	h := make(http.Header)
+	if a == nil {
+		return h, nil
+	}
+	for name, values := range a.headers {
+		h[name] = append([]string(nil), values...)
+	}
+
+	switch {
+	case a.basic:
+		credentials := base64.StdEncoding.EncodeToString([]byte(a.username + ":" + a.password))
+		h.Set("Authorization", "Basic "+credentials)
+	case a.jwtSecret != nil:
+		token, err := a.signJWT(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		h.Set("Authorization", "Bearer "+token)
+	}
+	return h, nil
+}
+
+// signJWT creates an HS256 token with an iat claim and, when configured, an id claim.
+func (a *endpointAuth) signJWT(now time.Time) (string, error) {
+	claims := map[string]interface{}{"iat": now.Unix()}
+	if a.jwtID != "" {
+		claims["id"] = a.jwtID
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode jwt claims: %w", err)
+	}
+
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + encoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, a.jwtSecret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + encoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,193 @@
+package endpoints
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+// testJWTSecret is a valid hex-encoded 32-byte secret.
+const testJWTSecret = "0x000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestEndpointAuthHeaders(t *testing.T) {
+	t.Setenv("TEST_RPC_PASSWORD", "from-env")
+	secretFile := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		endpoint config.EndpointConfig
+		want     http.Header
+	}{
+		{
+			name: "no authentication",
+			want: http.Header{},
+		},
+		{
+			name: "headers",
+			endpoint: config.EndpointConfig{Headers: map[string]config.SecretValue{
+				"x-api-key": {File: secretFile},
+				"X-Client":  {Value: "chain-view"},
+			}},
+			want: http.Header{"X-Api-Key": {"from-file"}, "X-Client": {"chain-view"}},
+		},
+		{
+			name: "basic auth",
+			endpoint: config.EndpointConfig{BasicAuth: &config.BasicAuthConfig{
+				Username: config.SecretValue{Value: "user"},
+				Password: config.SecretValue{Env: "TEST_RPC_PASSWORD"},
+			}},
+			want: http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte("user:from-env"))}},
+		},
+		{
+			name: "headers and basic auth",
+			endpoint: config.EndpointConfig{
+				Headers: map[string]config.SecretValue{"X-Client": {Value: "chain-view"}},
+				BasicAuth: &config.BasicAuthConfig{
+					Username: config.SecretValue{Value: "user"},
+					Password: config.SecretValue{Value: "secret"},
+				},
+			},
+			want: http.Header{
+				"X-Client":      {"chain-view"},
+				"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := newEndpointAuth(tt.endpoint)
+			if err != nil {
+				t.Fatalf("newEndpointAuth: %v", err)
+			}
+			got, err := auth.header()
+			if err != nil {
+				t.Fatalf("header: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("headers = %v, want %v", got, tt.want)
+			}
+			for name := range tt.want {
+				if got.Get(name) != tt.want.Get(name) {
+					t.Fatalf("%s = %q, want %q", name, got.Get(name), tt.want.Get(name))
+				}
+			}
+		})
+	}
+}
+
+func TestEndpointAuthErrors(t *testing.T) {
+	basic := &config.BasicAuthConfig{Username: config.SecretValue{Value: "user"}}
+	tests := []struct {
+		name     string
+		endpoint config.EndpointConfig
+		wantErr  string
+	}{
+		{
+			name:     "basic auth and jwt",
+			endpoint: config.EndpointConfig{BasicAuth: basic, JWT: &config.JWTConfig{Secret: config.SecretValue{Value: testJWTSecret}}},
+			wantErr:  "cannot both be set",
+		},
+		{
+			name:     "missing env var",
+			endpoint: config.EndpointConfig{Headers: map[string]config.SecretValue{"X-Api-Key": {Env: "TEST_RPC_UNSET_VARIABLE"}}},
+			wantErr:  "header X-Api-Key",
+		},
+		{
+			name:     "jwt secret not hex",
+			endpoint: config.EndpointConfig{JWT: &config.JWTConfig{Secret: config.SecretValue{Value: "not-hex"}}},
+			wantErr:  "hex encoded",
+		},
+		{
+			name:     "jwt secret too short",
+			endpoint: config.EndpointConfig{JWT: &config.JWTConfig{Secret: config.SecretValue{Value: "0x0102"}}},
+			wantErr:  "must be 32 bytes, got 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newEndpointAuth(tt.endpoint)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignJWT(t *testing.T) {
+	auth, err := newEndpointAuth(config.EndpointConfig{JWT: &config.JWTConfig{
+		Secret: config.SecretValue{Value: testJWTSecret},
+		ID:     "chain-view",
+	}})
+	if err != nil {
+		t.Fatalf("newEndpointAuth: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	token, err := auth.signJWT(now)
+	if err != nil {
+		t.Fatalf("signJWT: %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token %q does not have three parts", token)
+	}
+	mac := hmac.New(sha256.New, auth.jwtSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if want := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)); parts[2] != want {
+		t.Fatal("signature does not verify with the secret")
+	}
+
+	var header struct{ Alg, Typ string }
+	var claims struct {
+		IAT int64  `json:"iat"`
+		ID  string `json:"id"`
+	}
+	for i, v := range []interface{}{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			t.Fatalf("part %d is not base64url: %v", i, err)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+	}
+	if header.Alg != "HS256" || header.Typ != "JWT" {
+		t.Fatalf("header = %+v, want HS256 JWT", header)
+	}
+	if claims.IAT != now.Unix() || claims.ID != "chain-view" {
+		t.Fatalf("claims = %+v, want iat %d and id chain-view", claims, now.Unix())
+	}
+}
+
+func TestRequestsCarryJWT(t *testing.T) {
+	authorization := make(chan string, 1)
+	pool := newTestServerPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case authorization <- r.Header.Get("Authorization"):
+		default:
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}), withProvider(func(e *config.EndpointConfig) {
+		e.JWT = &config.JWTConfig{Secret: config.SecretValue{Value: testJWTSecret}}
+	}))
+
+	if _, err := pool.Forward(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`)); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	if got := <-authorization; !strings.HasPrefix(got, "Bearer ") || strings.Count(got, ".") != 2 {
+		t.Fatalf("Authorization = %q, want a bearer JWT", got)
+	}
+}
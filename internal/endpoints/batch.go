@@ -0,0 +1,238 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// JSON-RPC error codes used for batch elements that could not be answered.
+const (
+	codeInvalidRequest = -32600
+	codeInternalError  = -32603
+)
+
+// maxBatchConcurrency is the number of chunks of one batch forwarded at the same time.
+const maxBatchConcurrency = 4
+
+// BatchResult is the outcome of a JSON-RPC batch forwarded through the pool.
+type BatchResult struct {
+	Responses []json.RawMessage // one response per request with an id, in request order
+	Failed    int               // number of elements answered with a proxy error
+	Attempts  []Attempt         // every endpoint that was tried, across all chunks
+}
+
+// batchElement is a single request of a client batch.
+type batchElement struct {
+	index int
+	raw   json.RawMessage
+	id    json.RawMessage // nil for notifications
+}
+
+// ForwardBatch forwards the elements of a JSON-RPC batch and reassembles the responses in request order.
+// The batch is split into chunks that respect the smallest max_batch_size of the pool and,
+// when split_batches is enabled, spread over the available endpoints. Each chunk fails over
+// independently, and elements of a chunk that failed are answered with an error object.
+// Every element counts as one request against the rate limit and quota of the endpoint;
+// chunks wait for rate limit tokens up to the deadline of ctx.
+func (ep *EndpointPool) ForwardBatch(ctx context.Context, requests []json.RawMessage) *BatchResult {
+	elements := make([]batchElement, len(requests))
+	responses := make([]json.RawMessage, len(requests))
+	var valid []batchElement
+	for i, raw := range requests {
+		elements[i] = batchElement{index: i, raw: raw}
+		var envelope struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.Unmarshal(raw, &envelope); err != nil || envelope.Method == "" {
+			responses[i] = errorResponse(nil, codeInvalidRequest, "invalid request")
+			continue
+		}
+		elements[i].id = envelope.ID
+		valid = append(valid, elements[i])
+	}
+
+	result := &BatchResult{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxBatchConcurrency)
+	for _, chunk := range chunkElements(valid, ep.batchChunkSize(len(valid))) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(chunk []batchElement) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			chunkResponses, attempts := ep.forwardChunk(ctx, chunk)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Attempts = append(result.Attempts, attempts...)
+			for i, e := range chunk {
+				responses[e.index] = chunkResponses[i]
+			}
+		}(chunk)
+	}
+	wg.Wait()
+
+	for i, e := range elements {
+		if e.id == nil && responses[i] == nil {
+			// Notifications do not get a response
+			continue
+		}
+		if isProxyError(responses[i]) {
+			result.Failed++
+		}
+		result.Responses = append(result.Responses, responses[i])
+	}
+	return result
+}
+
+// batchChunkSize returns the number of elements sent to a single endpoint. A chunk is
+// no larger than the smallest burst, as the limiter can never grant more tokens at once.
+func (ep *EndpointPool) batchChunkSize(n int) int {
+	ep.mu.RLock()
+	split := ep.SplitBatches
+	ep.mu.RUnlock()
+
+	size := n
+	if split {
+		if available := len(ep.availableEndpoints()); available > 1 {
+			size = (n + available - 1) / available
+		}
+	}
+	for _, e := range ep.endpoints() {
+		if e.MaxBatchSize > 0 && e.MaxBatchSize < size {
+			size = e.MaxBatchSize
+		}
+		if burst := e.rateLimiter.Burst(); burst < size {
+			size = burst
+		}
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// chunkElements splits elements into chunks of at most size elements.
+func chunkElements(elements []batchElement, size int) [][]batchElement {
+	var chunks [][]batchElement
+	for len(elements) > 0 {
+		n := size
+		if n > len(elements) {
+			n = len(elements)
+		}
+		chunks = append(chunks, elements[:n])
+		elements = elements[n:]
+	}
+	return chunks
+}
+
+// forwardChunk forwards one chunk as a batch and matches the responses to the chunk elements by id.
+// Elements without a matching response get an error object; notifications get nil.
+func (ep *EndpointPool) forwardChunk(ctx context.Context, chunk []batchElement) ([]json.RawMessage, []Attempt) {
+	responses := make([]json.RawMessage, len(chunk))
+
+	payload := make([]json.RawMessage, len(chunk))
+	for i, e := range chunk {
+		payload[i] = e.raw
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fillErrors(chunk, responses, "failed to encode batch"), nil
+	}
+
+	forwarded, err := ep.forward(ctx, body, func(endpoint Endpoint) bool {
+		return ep.acquireWaitN(ctx, endpoint, len(chunk))
+	})
+	if err != nil {
+		return fillErrors(chunk, responses, "no healthy endpoint available"), forwarded.Attempts
+	}
+
+	var upstream []json.RawMessage
+	if err := json.Unmarshal(forwarded.Body, &upstream); err != nil {
+		// Providers that reject batches answer with a single error object
+		for i, e := range chunk {
+			if e.id != nil {
+				responses[i] = withID(forwarded.Body, e.id)
+			}
+		}
+		return responses, forwarded.Attempts
+	}
+
+	// Ids may repeat within a batch, so match them in order of appearance
+	pending := make(map[string][]int, len(chunk))
+	for i, e := range chunk {
+		if e.id != nil {
+			pending[string(e.id)] = append(pending[string(e.id)], i)
+		}
+	}
+	for _, resp := range upstream {
+		var envelope struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.Unmarshal(resp, &envelope); err != nil {
+			continue
+		}
+		key := string(envelope.ID)
+		if indexes := pending[key]; len(indexes) > 0 {
+			responses[indexes[0]] = resp
+			pending[key] = indexes[1:]
+		}
+	}
+
+	return fillErrors(chunk, responses, "no response from upstream"), forwarded.Attempts
+}
+
+// fillErrors sets an error object for every element with an id that has no response yet.
+func fillErrors(chunk []batchElement, responses []json.RawMessage, message string) []json.RawMessage {
+	for i, e := range chunk {
+		if e.id != nil && responses[i] == nil {
+			responses[i] = errorResponse(e.id, codeInternalError, message)
+		}
+	}
+	return responses
+}
+
+// withID returns a copy of a JSON-RPC response object with its id replaced.
+func withID(response, id json.RawMessage) json.RawMessage {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(response, &obj); err != nil {
+		return errorResponse(id, codeInternalError, "invalid response from upstream")
+	}
+	obj["id"] = id
+	out, _ := json.Marshal(obj)
+	return out
+}
+
+// proxyErrorMarker tags error objects generated by the pool so failed elements can be counted.
+const proxyErrorMarker = "chain-view"
+
+// errorResponse builds a JSON-RPC error response for a batch element.
+func errorResponse(id json.RawMessage, code int, message string) json.RawMessage {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	out, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": message,
+			"data":    proxyErrorMarker,
+		},
+	})
+	return out
+}
+
+// isProxyError reports whether a response was generated by errorResponse.
+func isProxyError(response json.RawMessage) bool {
+	var envelope struct {
+		Error *RPCError `json:"error"`
+	}
+	if err := json.Unmarshal(response, &envelope); err != nil || envelope.Error == nil {
+		return false
+	}
+	return string(envelope.Error.Data) == `"`+proxyErrorMarker+`"`
+}
@@ -0,0 +1,108 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+// batchHandler answers every element of a batch and tracks the number of concurrent requests.
+func batchHandler(inFlight, maxInFlight *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		var requests []struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&requests)
+		responses := make([]map[string]interface{}, len(requests))
+		for i, req := range requests {
+			responses[i] = map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": "0x1"}
+		}
+		json.NewEncoder(w).Encode(responses)
+	})
+}
+
+// testBatch returns a batch of n eth_chainId requests.
+func testBatch(n int) []json.RawMessage {
+	requests := make([]json.RawMessage, n)
+	for i := range requests {
+		requests[i] = json.RawMessage(`{"jsonrpc":"2.0","id":` + strconv.Itoa(i) + `,"method":"eth_chainId"}`)
+	}
+	return requests
+}
+
+func TestForwardBatchChargesEveryElement(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	pool := newTestServerPool(t, batchHandler(&inFlight, &maxInFlight), withProvider(func(e *config.EndpointConfig) {
+		e.RateLimit = 0.001
+		e.Burst = 100
+		e.MaxBatchSize = 2
+		e.Quota.Daily = 1000
+	}))
+
+	result := pool.ForwardBatch(context.Background(), testBatch(30))
+	if result.Failed != 0 {
+		t.Fatalf("%d elements failed", result.Failed)
+	}
+
+	endpoint := pool.endpoints()[0]
+	if used := 1000 - endpoint.quota.Usage().DailyRemaining; used != 30 {
+		t.Fatalf("quota used = %d, want one per element", used)
+	}
+	if tokens := endpoint.rateLimiter.Tokens(); tokens > 70.5 {
+		t.Fatalf("rate limit tokens left = %v, want one taken per element", tokens)
+	}
+	if max := maxInFlight.Load(); max > maxBatchConcurrency {
+		t.Fatalf("%d chunks in flight, want at most %d", max, maxBatchConcurrency)
+	}
+}
+
+func TestForwardBatchWaitsForRateLimitTokens(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	pool := newTestServerPool(t, batchHandler(&inFlight, &maxInFlight), withProvider(func(e *config.EndpointConfig) {
+		e.RateLimit = 50
+		e.Burst = 10
+	}))
+
+	// Three times the burst, so later chunks have to wait for tokens
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if result := pool.ForwardBatch(ctx, testBatch(30)); result.Failed != 0 {
+		t.Fatalf("%d elements failed", result.Failed)
+	}
+
+	// Tokens that cannot arrive before the deadline are not waited for
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if result := pool.ForwardBatch(ctx, testBatch(30)); result.Failed == 0 {
+		t.Fatal("batch succeeded although the tokens could not arrive before the deadline")
+	}
+}
+
+func TestForwardBatchChecksBreakerBeforeTakingTokens(t *testing.T) {
+	pool := newTestServerPool(t, nil, withTrialBreaker)
+	endpoint := pool.endpoints()[0]
+	endpoint.circuitBreaker.RecordFailure()
+
+	if result := pool.ForwardBatch(context.Background(), testBatch(5)); result.Failed != 5 {
+		t.Fatalf("%d elements failed, want all 5", result.Failed)
+	}
+	if tokens := endpoint.rateLimiter.Tokens(); tokens < float64(defaultBurst)-0.5 {
+		t.Fatalf("rate limit tokens left = %v, want none taken by an open breaker", tokens)
+	}
+}
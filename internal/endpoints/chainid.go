@@ -0,0 +1,187 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Chain ID verification intervals.
+const (
+	defaultChainIDCheckInterval = 5 * time.Minute  // used when the chain does not set chain_id_check_interval
+	chainIDRetryInterval        = 30 * time.Second // used while an endpoint could not be verified
+)
+
+// chainIDTracker keeps the endpoints that reported a chain ID other than the configured one.
+// Quarantined endpoints are left out of selection, probing and head tracking until they
+// report the right chain again.
+type chainIDTracker struct {
+	mu          sync.Mutex
+	quarantined map[string]bool
+}
+
+// newChainIDTracker creates an empty chainIDTracker.
+func newChainIDTracker() *chainIDTracker {
+	return &chainIDTracker{quarantined: make(map[string]bool)}
+}
+
+// set records whether an endpoint is quarantined and reports whether that changed.
+func (t *chainIDTracker) set(endpoint string, quarantined bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	changed := t.quarantined[endpoint] != quarantined
+	t.quarantined[endpoint] = quarantined
+	return changed
+}
+
+// isQuarantined reports whether the endpoint reported the wrong chain ID on its last check.
+func (t *chainIDTracker) isQuarantined(endpoint string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.quarantined[endpoint]
+}
+
+// retain forgets every endpoint that is not in names.
+func (t *chainIDTracker) retain(names map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for name := range t.quarantined {
+		if !names[name] {
+			delete(t.quarantined, name)
+		}
+	}
+}
+
+// VerifyChainIDs asks every endpoint for its chain ID when started, after each reload and
+// then periodically until ctx is canceled. Endpoints that could not be asked are retried
+// sooner than the check interval. Endpoints disabled by an operator are skipped.
+func (ep *EndpointPool) VerifyChainIDs(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-ep.chainIDRecheck:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+
+		next := ep.chainIDCheckInterval()
+		for _, endpoint := range ep.endpoints() {
+			if ep.control.state(endpoint.Name) != AdminEnabled {
+				continue
+			}
+			if err := ep.verifyChainID(ctx, endpoint); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				ep.logger.WithError(err).WithFields(logrus.Fields{
+					"network":  ep.Network,
+					"endpoint": endpoint.Name,
+				}).Warn("Failed to verify endpoint chain ID")
+				if next > chainIDRetryInterval {
+					next = chainIDRetryInterval
+				}
+			}
+		}
+		timer.Reset(next)
+	}
+}
+
+// requestChainIDCheck makes VerifyChainIDs check every endpoint without waiting for the interval.
+func (ep *EndpointPool) requestChainIDCheck() {
+	select {
+	case ep.chainIDRecheck <- struct{}{}:
+	default:
+	}
+}
+
+// chainIDCheckInterval returns the configured check interval, applying the default.
+func (ep *EndpointPool) chainIDCheckInterval() time.Duration {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	if ep.ChainIDCheckInterval <= 0 {
+		return defaultChainIDCheckInterval
+	}
+	return ep.ChainIDCheckInterval
+}
+
+// verifyChainID asks an endpoint which chain it serves and quarantines it when that is not
+// the configured chain. eth_chainId decides; net_version is only used when eth_chainId is
+// not supported, because the network ID of some chains legitimately differs from the chain ID.
+// An endpoint that answers neither keeps its previous state and the error is returned.
+func (ep *EndpointPool) verifyChainID(ctx context.Context, endpoint Endpoint) error {
+	chainID, chainIDErr := ep.queryChainID(ctx, endpoint, "eth_chainId", ParseHexUint64)
+	networkID, networkIDErr := ep.queryChainID(ctx, endpoint, "net_version", parseDecimalUint64)
+
+	fields := logrus.Fields{
+		"network":           ep.Network,
+		"endpoint":          endpoint.Name,
+		"url":               endpoint.RedactedURL(),
+		"expected_chain_id": ep.ChainID,
+	}
+	reported := chainID
+	switch {
+	case chainIDErr == nil:
+		fields["chain_id"] = chainID
+		if networkIDErr == nil {
+			fields["network_id"] = networkID
+		}
+	case networkIDErr == nil:
+		reported = networkID
+		fields["network_id"] = networkID
+	default:
+		return fmt.Errorf("eth_chainId: %w; net_version: %v", chainIDErr, networkIDErr)
+	}
+
+	mismatch := reported != uint64(ep.ChainID)
+	ep.metrics.SetChainIDMismatch(ep.Network, endpoint.Name, reported, mismatch)
+	if !ep.chainIDs.set(endpoint.Name, mismatch) {
+		return nil
+	}
+	if mismatch {
+		// Heads recorded before the check would otherwise keep counting, and a chain with
+		// higher block numbers would make every healthy endpoint look degraded
+		ep.forgetHead(endpoint.Name)
+		ep.reorgs.forget(endpoint.Name)
+		ep.logger.WithFields(fields).Error("Endpoint serves a different chain than configured, quarantining it")
+	} else {
+		ep.logger.WithFields(fields).Info("Endpoint chain ID matches the configuration again, lifting quarantine")
+	}
+	return nil
+}
+
+// queryChainID calls a method returning a chain or network ID and parses the result.
+func (ep *EndpointPool) queryChainID(ctx context.Context, endpoint Endpoint, method string, parse func(string) (uint64, error)) (uint64, error) {
+	if err := endpoint.rateLimiter.Wait(ctx); err != nil {
+		return 0, err
+	}
+	if !endpoint.IsWebSocket() && !ep.consumeQuota(endpoint) {
+		return 0, fmt.Errorf("quota exhausted")
+	}
+
+	var raw string
+	if err := ep.callRPC(ctx, endpoint, method, nil, &raw); err != nil {
+		return 0, err
+	}
+	return parse(raw)
+}
+
+// parseDecimalUint64 parses a decimal quantity such as the result of net_version.
+func parseDecimalUint64(s string) (uint64, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal quantity %q: %w", s, err)
+	}
+	return v, nil
+}
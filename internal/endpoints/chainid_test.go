@@ -0,0 +1,47 @@
+package endpoints
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+func TestQuarantineForgetsHeadsOfOtherChain(t *testing.T) {
+	// An Arbitrum endpoint configured in a mainnet pool
+	pool := newTestServerPool(t, rpcResult(`"0xa4b1"`), withEndpoint("healthy", unreachableURL), func(chain *config.ChainConfig) {
+		chain.MaxBlockLag = 10
+		chain.Endpoints[0].Name = "arbitrum"
+	})
+
+	pool.recordBlock("healthy", blockHead{Number: 100, Hash: "0xa"})
+	pool.recordBlock("arbitrum", blockHead{Number: 250000000, Hash: "0xb"})
+	if !pool.heads.isDegraded("healthy") {
+		t.Fatal("healthy endpoint is not degraded by the higher head of the other chain")
+	}
+
+	arbEndpoint, err := pool.findEndpoint("arbitrum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.verifyChainID(context.Background(), arbEndpoint); err != nil {
+		t.Fatalf("verifyChainID: %v", err)
+	}
+
+	if !pool.chainIDs.isQuarantined("arbitrum") {
+		t.Fatal("endpoint of another chain is not quarantined")
+	}
+	if pool.heads.isDegraded("healthy") {
+		t.Fatal("healthy endpoint is still degraded after the quarantine")
+	}
+	if best := pool.BestHead(); best != 100 {
+		t.Fatalf("BestHead() = %d, want 100", best)
+	}
+	healthy, _ := pool.findEndpoint("healthy")
+	if !pool.isAvailable(healthy) {
+		t.Fatal("healthy endpoint is not available")
+	}
+	if _, ok := pool.reorgs.hashes["arbitrum"]; ok {
+		t.Fatal("block hashes of the quarantined endpoint are kept")
+	}
+}
@@ -0,0 +1,113 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pampatzoglou/chain-view/internal/database"
+)
+
+// Error classes stored with failed endpoint checks.
+const (
+	ErrorClassTimeout         = "timeout"
+	ErrorClassConnection      = "connection"
+	ErrorClassRateLimited     = "rate_limited"
+	ErrorClassHTTPStatus      = "http_status"
+	ErrorClassRPCError        = "rpc_error"
+	ErrorClassInvalidResponse = "invalid_response"
+	ErrorClassStale           = "stale"
+	ErrorClassOther           = "other"
+
+	// Probes that were skipped without contacting the endpoint
+	ErrorClassCircuitOpen    = "circuit_open"
+	ErrorClassQuotaExhausted = "quota_exhausted"
+	ErrorClassLocalRateLimit = "local_rate_limit" // our own limiter, unlike rate_limited for a provider 429
+)
+
+// classifyProbeError returns the error class of a failed probe, or an empty string for nil.
+func classifyProbeError(err error) string {
+	var (
+		netErr    net.Error
+		statusErr *HTTPStatusError
+		rpcErr    *RPCError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		numErr    *strconv.NumError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	case errors.As(err, &statusErr):
+		if statusErr.StatusCode == http.StatusTooManyRequests {
+			return ErrorClassRateLimited
+		}
+		return ErrorClassHTTPStatus
+	case errors.As(err, &rpcErr):
+		return ErrorClassRPCError
+	case errors.As(err, &netErr), errors.Is(err, errNotConnected):
+		return ErrorClassConnection
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.As(err, &numErr), errors.Is(err, errEmptyResult):
+		return ErrorClassInvalidResponse
+	case errors.Is(err, errSubscriptionStale):
+		return ErrorClassStale
+	default:
+		return ErrorClassOther
+	}
+}
+
+// probeHTTPCode returns the HTTP status a probe received, or 0 when there was none:
+// for WebSocket endpoints and for requests that failed before a response arrived.
+func probeHTTPCode(endpoint Endpoint, err error) int {
+	if endpoint.IsWebSocket() {
+		return 0
+	}
+	var statusErr *HTTPStatusError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode
+	case err == nil, classifyProbeError(err) == ErrorClassRPCError, classifyProbeError(err) == ErrorClassInvalidResponse:
+		return http.StatusOK
+	default:
+		return 0
+	}
+}
+
+// recordCheck queues the outcome of a probe for the endpoint_checks history.
+func (ep *EndpointPool) recordCheck(endpoint Endpoint, attempt Attempt, head blockHead) {
+	check := database.EndpointCheck{
+		CheckedAt:  attempt.At,
+		Chain:      ep.Network,
+		ChainID:    ep.ChainID,
+		Endpoint:   endpoint.Name,
+		Status:     database.CheckStatusUp,
+		HTTPCode:   probeHTTPCode(endpoint, attempt.Err),
+		Latency:    attempt.Duration,
+		ErrorClass: classifyProbeError(attempt.Err),
+	}
+	if attempt.Err == nil {
+		check.BlockHeight = head.Number
+	} else {
+		check.Status = database.CheckStatusDown
+	}
+	ep.checks.Record(check)
+}
+
+// recordSkippedCheck records a probe that was not sent because of the pool's own limits,
+// with the reason as its error class. Skipped checks do not count as downtime.
+func (ep *EndpointPool) recordSkippedCheck(endpoint Endpoint, errorClass string) {
+	ep.checks.Record(database.EndpointCheck{
+		CheckedAt:  time.Now(),
+		Chain:      ep.Network,
+		ChainID:    ep.ChainID,
+		Endpoint:   endpoint.Name,
+		Status:     database.CheckStatusSkipped,
+		ErrorClass: errorClass,
+	})
+}
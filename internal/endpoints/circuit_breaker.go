@@ -0,0 +1,310 @@
+package endpoints
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+// Circuit breaker states.
+const (
+	StateClosed   = "closed"
+	StateHalfOpen = "half-open"
+	StateOpen     = "open"
+)
+
+// circuitStateValues maps circuit breaker states to the values exported as a gauge.
+var circuitStateValues = map[string]float64{
+	StateClosed:   0,
+	StateHalfOpen: 1,
+	StateOpen:     2,
+}
+
+// Default circuit breaker settings used when neither the chain nor the endpoint sets a value.
+const (
+	defaultFailureThreshold    = 3
+	defaultOpenDuration        = 10 * time.Second
+	defaultHalfOpenMaxRequests = 1
+	defaultFailureWindow       = time.Minute
+	defaultMinRequests         = 10
+)
+
+// CircuitBreakerSettings holds the resolved thresholds of a circuit breaker.
+type CircuitBreakerSettings struct {
+	// FailureThreshold trips the breaker after this many consecutive failures.
+	FailureThreshold int
+	// FailureRate trips the breaker when the share of failures within Window reaches it.
+	// Zero disables the rate check.
+	FailureRate float64
+	// Window is the rolling window used for the failure rate.
+	Window time.Duration
+	// MinRequests is the number of requests within Window required before the rate is evaluated.
+	MinRequests int
+	// OpenDuration is how long the breaker stays open before allowing trial requests.
+	OpenDuration time.Duration
+	// HalfOpenMaxRequests is the number of trial requests allowed while half-open.
+	// All of them must succeed for the breaker to close again.
+	HalfOpenMaxRequests int
+}
+
+// resolveCircuitBreakerSettings merges the chain and endpoint circuit breaker configuration.
+// Endpoint values take precedence over chain values, which take precedence over the defaults.
+func resolveCircuitBreakerSettings(chain, endpoint config.CircuitBreakerConfig) CircuitBreakerSettings {
+	s := CircuitBreakerSettings{
+		FailureThreshold:    defaultFailureThreshold,
+		Window:              defaultFailureWindow,
+		MinRequests:         defaultMinRequests,
+		OpenDuration:        defaultOpenDuration,
+		HalfOpenMaxRequests: defaultHalfOpenMaxRequests,
+	}
+	for _, c := range []config.CircuitBreakerConfig{chain, endpoint} {
+		if c.FailureThreshold > 0 {
+			s.FailureThreshold = c.FailureThreshold
+		}
+		if c.FailureRate > 0 {
+			s.FailureRate = c.FailureRate
+		}
+		if c.Window.Duration > 0 {
+			s.Window = c.Window.Duration
+		}
+		if c.MinRequests > 0 {
+			s.MinRequests = c.MinRequests
+		}
+		if c.OpenDuration.Duration > 0 {
+			s.OpenDuration = c.OpenDuration.Duration
+		}
+		if c.HalfOpenMaxRequests > 0 {
+			s.HalfOpenMaxRequests = c.HalfOpenMaxRequests
+		}
+	}
+	return s
+}
+
+// outcome is a single request result kept for the rolling failure rate.
+type outcome struct {
+	at     time.Time
+	failed bool
+}
+
+// CircuitBreaker is a closed/open/half-open circuit breaker driven by timestamps.
+type CircuitBreaker struct {
+	settings  CircuitBreakerSettings
+	state     string
+	failures  int // consecutive failures while closed
+	successes int
+
+	openedAt          time.Time
+	halfOpenAdmitted  int
+	halfOpenSucceeded int
+	outcomes          []outcome
+	forced            bool // held open by ForceState until forced closed
+
+	// onTransition is called on every state change while the breaker lock is held.
+	// It must not call back into the breaker.
+	onTransition func(from, to string)
+	now          func() time.Time
+	mu           sync.Mutex
+}
+
+// CircuitBreakerMetrics stores metrics for the circuit breaker.
+type CircuitBreakerMetrics struct {
+	Failures     int
+	Successes    int
+	CircuitState string
+	Forced       bool
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker in the closed state.
+func NewCircuitBreaker(settings CircuitBreakerSettings, onTransition func(from, to string)) *CircuitBreaker {
+	return &CircuitBreaker{
+		settings:     settings,
+		state:        StateClosed,
+		onTransition: onTransition,
+		now:          time.Now,
+	}
+}
+
+// SetSettings replaces the thresholds of the breaker, keeping its state and counters.
+// They apply from the next request; a half-open breaker whose trials already all
+// succeeded under the new settings closes at its next success.
+func (cb *CircuitBreaker) SetSettings(settings CircuitBreakerSettings) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.settings = settings
+}
+
+// Allow reports whether a request may be sent and, while half-open, reserves one of the trial slots.
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.currentState() {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		if cb.halfOpenAdmitted >= cb.settings.HalfOpenMaxRequests {
+			return false
+		}
+		cb.halfOpenAdmitted++
+		return true
+	default:
+		return true
+	}
+}
+
+// Release returns a trial slot reserved by Allow for a request that was never sent,
+// for example because the endpoint's quota ran out. It has no effect unless half-open.
+func (cb *CircuitBreaker) Release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.currentState() == StateHalfOpen && cb.halfOpenAdmitted > 0 {
+		cb.halfOpenAdmitted--
+	}
+}
+
+// Ready reports whether Allow would currently admit a request, without reserving a trial slot.
+func (cb *CircuitBreaker) Ready() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.currentState() {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		return cb.halfOpenAdmitted < cb.settings.HalfOpenMaxRequests
+	default:
+		return true
+	}
+}
+
+// RecordFailure records a failed request and trips the breaker if a threshold is crossed.
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.now()
+	switch cb.currentState() {
+	case StateHalfOpen:
+		// Any failed trial sends the breaker straight back to open
+		cb.trip(now)
+	case StateClosed:
+		cb.failures++
+		cb.record(now, true)
+		if cb.failures >= cb.settings.FailureThreshold || cb.failureRateExceeded() {
+			cb.trip(now)
+		}
+	}
+}
+
+// RecordSuccess records a successful request and closes the breaker once all half-open trials succeed.
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.successes++
+	switch cb.currentState() {
+	case StateHalfOpen:
+		cb.halfOpenSucceeded++
+		if cb.halfOpenSucceeded >= cb.settings.HalfOpenMaxRequests {
+			cb.transition(StateClosed)
+		}
+	case StateClosed:
+		cb.failures = 0
+		cb.record(cb.now(), false)
+	}
+}
+
+// GetMetrics returns the current metrics of the circuit breaker.
+func (cb *CircuitBreaker) GetMetrics() CircuitBreakerMetrics {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return CircuitBreakerMetrics{
+		Failures:     cb.failures,
+		Successes:    cb.successes,
+		CircuitState: cb.currentState(),
+		Forced:       cb.forced,
+	}
+}
+
+// ForceState overrides the breaker state. A breaker forced open rejects every request
+// until it is forced closed; forcing it closed resets the counters and resumes normal
+// tripping. Half-open cannot be forced.
+func (cb *CircuitBreaker) ForceState(state string) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch state {
+	case StateOpen:
+		cb.forced = true
+		cb.trip(cb.now())
+	case StateClosed:
+		cb.forced = false
+		cb.transition(StateClosed)
+		cb.failures = 0
+		cb.outcomes = nil
+	default:
+		return fmt.Errorf("circuit breaker can only be forced %s or %s, not %q", StateOpen, StateClosed, state)
+	}
+	return nil
+}
+
+// currentState returns the state, moving an expired open breaker to half-open unless it was
+// forced open. Callers hold cb.mu.
+func (cb *CircuitBreaker) currentState() string {
+	if cb.state == StateOpen && !cb.forced && !cb.now().Before(cb.openedAt.Add(cb.settings.OpenDuration)) {
+		cb.transition(StateHalfOpen)
+	}
+	return cb.state
+}
+
+// trip opens the breaker. Callers hold cb.mu.
+func (cb *CircuitBreaker) trip(now time.Time) {
+	cb.openedAt = now
+	cb.transition(StateOpen)
+}
+
+// transition changes the state and resets the per-state counters. Callers hold cb.mu.
+func (cb *CircuitBreaker) transition(to string) {
+	from := cb.state
+	if from == to {
+		return
+	}
+	cb.state = to
+	cb.halfOpenAdmitted = 0
+	cb.halfOpenSucceeded = 0
+	if to == StateClosed {
+		cb.failures = 0
+		cb.outcomes = nil
+	}
+	if cb.onTransition != nil {
+		cb.onTransition(from, to)
+	}
+}
+
+// record appends an outcome to the rolling window and drops expired ones. Callers hold cb.mu.
+func (cb *CircuitBreaker) record(now time.Time, failed bool) {
+	cutoff := now.Add(-cb.settings.Window)
+	i := 0
+	for i < len(cb.outcomes) && cb.outcomes[i].at.Before(cutoff) {
+		i++
+	}
+	cb.outcomes = append(cb.outcomes[i:], outcome{at: now, failed: failed})
+}
+
+// failureRateExceeded reports whether the failure rate within the window crossed the threshold. Callers hold cb.mu.
+func (cb *CircuitBreaker) failureRateExceeded() bool {
+	if cb.settings.FailureRate <= 0 || len(cb.outcomes) < cb.settings.MinRequests {
+		return false
+	}
+	failed := 0
+	for _, o := range cb.outcomes {
+		if o.failed {
+			failed++
+		}
+	}
+	return float64(failed)/float64(len(cb.outcomes)) >= cb.settings.FailureRate
+}
@@ -0,0 +1,236 @@
+package endpoints
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+// newTestBreaker creates a breaker driven by clock that records its transitions.
+func newTestBreaker(settings CircuitBreakerSettings, clock *fakeClock, transitions *[]string) *CircuitBreaker {
+	cb := NewCircuitBreaker(settings, func(from, to string) {
+		*transitions = append(*transitions, from+">"+to)
+	})
+	cb.now = clock.now
+	return cb
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	settings := CircuitBreakerSettings{
+		FailureThreshold:    2,
+		Window:              time.Minute,
+		MinRequests:         10,
+		OpenDuration:        10 * time.Second,
+		HalfOpenMaxRequests: 2,
+	}
+
+	// Each step applies an action and checks the resulting state.
+	type step struct {
+		action string // allow, deny, ready, success, failure, release or wait
+		wait   time.Duration
+		state  string
+	}
+	tests := []struct {
+		name        string
+		settings    CircuitBreakerSettings
+		steps       []step
+		transitions []string
+	}{
+		{
+			name:     "closed to open to half-open to closed",
+			settings: settings,
+			steps: []step{
+				{action: "allow", state: StateClosed},
+				{action: "failure", state: StateClosed},
+				{action: "failure", state: StateOpen},
+				{action: "deny", state: StateOpen},
+				{action: "wait", wait: 9 * time.Second, state: StateOpen},
+				{action: "wait", wait: time.Second, state: StateHalfOpen},
+				{action: "allow", state: StateHalfOpen},
+				{action: "allow", state: StateHalfOpen},
+				{action: "deny", state: StateHalfOpen},
+				{action: "success", state: StateHalfOpen},
+				{action: "success", state: StateClosed},
+				{action: "allow", state: StateClosed},
+			},
+			transitions: []string{"closed>open", "open>half-open", "half-open>closed"},
+		},
+		{
+			name:     "failed trial reopens",
+			settings: settings,
+			steps: []step{
+				{action: "failure", state: StateClosed},
+				{action: "failure", state: StateOpen},
+				{action: "wait", wait: 10 * time.Second, state: StateHalfOpen},
+				{action: "allow", state: StateHalfOpen},
+				{action: "failure", state: StateOpen},
+				{action: "deny", state: StateOpen},
+			},
+			transitions: []string{"closed>open", "open>half-open", "half-open>open"},
+		},
+		{
+			name:     "success resets consecutive failures",
+			settings: settings,
+			steps: []step{
+				{action: "failure", state: StateClosed},
+				{action: "success", state: StateClosed},
+				{action: "failure", state: StateClosed},
+			},
+		},
+		{
+			name: "failure rate trips",
+			settings: CircuitBreakerSettings{
+				FailureThreshold:    100,
+				FailureRate:         0.5,
+				Window:              time.Minute,
+				MinRequests:         4,
+				OpenDuration:        time.Second,
+				HalfOpenMaxRequests: 1,
+			},
+			steps: []step{
+				{action: "failure", state: StateClosed},
+				{action: "success", state: StateClosed},
+				{action: "failure", state: StateClosed},
+				{action: "success", state: StateClosed},
+				{action: "failure", state: StateOpen},
+			},
+			transitions: []string{"closed>open"},
+		},
+		{
+			name:     "unsent trial request releases its slot",
+			settings: settings,
+			steps: []step{
+				{action: "failure", state: StateClosed},
+				{action: "failure", state: StateOpen},
+				{action: "wait", wait: 10 * time.Second, state: StateHalfOpen},
+				{action: "allow", state: StateHalfOpen},
+				{action: "allow", state: StateHalfOpen},
+				{action: "deny", state: StateHalfOpen},
+				// Neither request was sent, e.g. because the quota ran out
+				{action: "release", state: StateHalfOpen},
+				{action: "release", state: StateHalfOpen},
+				{action: "ready", state: StateHalfOpen},
+				{action: "allow", state: StateHalfOpen},
+				{action: "success", state: StateHalfOpen},
+				{action: "allow", state: StateHalfOpen},
+				{action: "success", state: StateClosed},
+			},
+			transitions: []string{"closed>open", "open>half-open", "half-open>closed"},
+		},
+		{
+			name:     "release while closed has no effect",
+			settings: settings,
+			steps: []step{
+				{action: "release", state: StateClosed},
+				{action: "failure", state: StateClosed},
+				{action: "failure", state: StateOpen},
+				{action: "wait", wait: 10 * time.Second, state: StateHalfOpen},
+				{action: "release", state: StateHalfOpen},
+				{action: "allow", state: StateHalfOpen},
+				{action: "allow", state: StateHalfOpen},
+				{action: "deny", state: StateHalfOpen},
+			},
+			transitions: []string{"closed>open", "open>half-open"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{t: time.Unix(1700000000, 0)}
+			var transitions []string
+			cb := newTestBreaker(tt.settings, clock, &transitions)
+
+			for i, s := range tt.steps {
+				switch s.action {
+				case "allow":
+					if !cb.Allow() {
+						t.Fatalf("step %d: Allow() = false, want true", i)
+					}
+				case "deny":
+					if cb.Allow() {
+						t.Fatalf("step %d: Allow() = true, want false", i)
+					}
+				case "ready":
+					if !cb.Ready() {
+						t.Fatalf("step %d: Ready() = false, want true", i)
+					}
+				case "success":
+					cb.RecordSuccess()
+				case "failure":
+					cb.RecordFailure()
+				case "release":
+					cb.Release()
+				case "wait":
+					clock.advance(s.wait)
+				}
+				if got := cb.GetMetrics().CircuitState; got != s.state {
+					t.Fatalf("step %d (%s): state = %s, want %s", i, s.action, got, s.state)
+				}
+			}
+
+			if len(transitions) != len(tt.transitions) {
+				t.Fatalf("transitions = %v, want %v", transitions, tt.transitions)
+			}
+			for i := range transitions {
+				if transitions[i] != tt.transitions[i] {
+					t.Fatalf("transitions = %v, want %v", transitions, tt.transitions)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerForceState(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	var transitions []string
+	cb := newTestBreaker(CircuitBreakerSettings{
+		FailureThreshold:    1,
+		Window:              time.Minute,
+		OpenDuration:        time.Second,
+		HalfOpenMaxRequests: 1,
+	}, clock, &transitions)
+
+	if err := cb.ForceState(StateHalfOpen); err == nil {
+		t.Fatal("ForceState(half-open) succeeded, want an error")
+	}
+	if err := cb.ForceState(StateOpen); err != nil {
+		t.Fatalf("ForceState(open): %v", err)
+	}
+	clock.advance(time.Hour)
+	if cb.Allow() || cb.GetMetrics().CircuitState != StateOpen || !cb.GetMetrics().Forced {
+		t.Fatalf("forced open breaker moved on: %+v", cb.GetMetrics())
+	}
+	if err := cb.ForceState(StateClosed); err != nil {
+		t.Fatalf("ForceState(closed): %v", err)
+	}
+	if !cb.Allow() || cb.GetMetrics().Forced {
+		t.Fatalf("breaker forced closed does not admit requests: %+v", cb.GetMetrics())
+	}
+}
+
+func TestAcquireReleasesTrialSlotWhenQuotaIsExhausted(t *testing.T) {
+	pool := newTestServerPool(t, nil, withProvider(func(e *config.EndpointConfig) {
+		e.Quota.Daily = 1
+	}), withTrialBreaker)
+	endpoint := pool.endpoints()[0]
+	clock := &fakeClock{t: time.Now()}
+	endpoint.circuitBreaker.now = clock.now
+
+	// A breaker opened by rate limiting, then the local quota runs out
+	endpoint.circuitBreaker.RecordFailure()
+	if !pool.consumeQuota(endpoint) {
+		t.Fatal("first request exceeded a quota of 1")
+	}
+	clock.advance(time.Hour)
+
+	if pool.acquire(endpoint) {
+		t.Fatal("acquire succeeded with an exhausted quota")
+	}
+	if state := endpoint.circuitBreaker.GetMetrics().CircuitState; state != StateHalfOpen {
+		t.Fatalf("state = %s, want %s", state, StateHalfOpen)
+	}
+	if !endpoint.circuitBreaker.Ready() {
+		t.Fatal("trial slot of the unsent request was not released")
+	}
+}
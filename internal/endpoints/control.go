@@ -0,0 +1,210 @@
+package endpoints
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Rotation states of an endpoint, changed at runtime through the admin API.
+const (
+	AdminEnabled  = "enabled"
+	AdminDraining = "draining" // out of rotation, disabled once in-flight requests finish
+	AdminDisabled = "disabled"
+)
+
+// adminStateValues maps rotation states to the values exported as a gauge.
+var adminStateValues = map[string]float64{
+	AdminEnabled:  0,
+	AdminDraining: 1,
+	AdminDisabled: 2,
+}
+
+// ErrEndpointNotFound is returned when an admin action names an endpoint the pool does not have.
+var ErrEndpointNotFound = errors.New("endpoint not found")
+
+// endpointControl keeps the rotation state set by operators and the number of in-flight
+// requests of every endpoint. It is keyed by endpoint name, so the state survives reloads.
+type endpointControl struct {
+	mu       sync.Mutex
+	states   map[string]controlState
+	inflight map[string]int
+}
+
+// controlState is the rotation state of an endpoint and who set it.
+type controlState struct {
+	state string
+	actor string
+}
+
+// newEndpointControl creates an endpointControl with every endpoint enabled.
+func newEndpointControl() *endpointControl {
+	return &endpointControl{
+		states:   make(map[string]controlState),
+		inflight: make(map[string]int),
+	}
+}
+
+// state returns the rotation state of an endpoint.
+func (c *endpointControl) state(endpoint string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.states[endpoint]; ok {
+		return s.state
+	}
+	return AdminEnabled
+}
+
+// set changes the rotation state of an endpoint. Draining an endpoint without in-flight
+// requests disables it right away. The resulting state is returned.
+func (c *endpointControl) set(endpoint, state, actor string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if state == AdminDraining && c.inflight[endpoint] == 0 {
+		state = AdminDisabled
+	}
+	if state == AdminEnabled {
+		delete(c.states, endpoint)
+	} else {
+		c.states[endpoint] = controlState{state: state, actor: actor}
+	}
+	return state
+}
+
+// begin counts a request sent to an endpoint.
+func (c *endpointControl) begin(endpoint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight[endpoint]++
+}
+
+// end counts a finished request. When it was the last one of a draining endpoint, the
+// endpoint is disabled and the actor who drained it is returned.
+func (c *endpointControl) end(endpoint string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inflight[endpoint]--
+	if c.inflight[endpoint] > 0 {
+		return "", false
+	}
+	delete(c.inflight, endpoint)
+	s, ok := c.states[endpoint]
+	if !ok || s.state != AdminDraining {
+		return "", false
+	}
+	c.states[endpoint] = controlState{state: AdminDisabled, actor: s.actor}
+	return s.actor, true
+}
+
+// inFlight returns the number of requests currently sent to an endpoint.
+func (c *endpointControl) inFlight(endpoint string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inflight[endpoint]
+}
+
+// retain forgets the rotation state of every endpoint that is not in names.
+// In-flight counters are kept until their requests finish.
+func (c *endpointControl) retain(names map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range c.states {
+		if !names[name] {
+			delete(c.states, name)
+		}
+	}
+}
+
+// inRotation reports whether an endpoint may receive requests at all: it is enabled by
+// operators and serves the configured chain.
+func (ep *EndpointPool) inRotation(endpoint string) bool {
+	return ep.control.state(endpoint) == AdminEnabled && !ep.chainIDs.isQuarantined(endpoint)
+}
+
+// trackRequest counts a request to an endpoint as in flight. The returned function must be
+// called when the request finishes; it completes the drain of a draining endpoint.
+func (ep *EndpointPool) trackRequest(endpoint string) func() {
+	ep.control.begin(endpoint)
+	return func() {
+		if actor, drained := ep.control.end(endpoint); drained {
+			ep.metrics.EndpointAdminState.WithLabelValues(ep.Network, endpoint).Set(adminStateValues[AdminDisabled])
+			ep.logger.WithFields(logrus.Fields{
+				"network":  ep.Network,
+				"endpoint": endpoint,
+				"actor":    actor,
+			}).Warn("Endpoint drained, now disabled")
+		}
+	}
+}
+
+// findEndpoint returns the current endpoint with the given name.
+func (ep *EndpointPool) findEndpoint(name string) (Endpoint, error) {
+	for _, e := range ep.endpoints() {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return Endpoint{}, fmt.Errorf("%w: %s has no endpoint %q", ErrEndpointNotFound, ep.Network, name)
+}
+
+// SetEndpointState takes an endpoint out of rotation or puts it back. AdminDisabled removes
+// it immediately; requests already sent to it still complete. AdminDraining stops new
+// requests and disables it once the in-flight ones finish. AdminEnabled puts it back.
+// The change is logged with actor, the identity of the caller.
+func (ep *EndpointPool) SetEndpointState(name, state, actor string) (EndpointStatus, error) {
+	if _, ok := adminStateValues[state]; !ok {
+		return EndpointStatus{}, fmt.Errorf("unknown endpoint state %q", state)
+	}
+	if _, err := ep.findEndpoint(name); err != nil {
+		return EndpointStatus{}, err
+	}
+
+	previous := ep.control.state(name)
+	current := ep.control.set(name, state, actor)
+	ep.metrics.EndpointAdminState.WithLabelValues(ep.Network, name).Set(adminStateValues[current])
+	ep.metrics.AdminActions.WithLabelValues(ep.Network, name, state).Inc()
+
+	fields := logrus.Fields{
+		"network":   ep.Network,
+		"endpoint":  name,
+		"actor":     actor,
+		"from":      previous,
+		"to":        current,
+		"in_flight": ep.control.inFlight(name),
+	}
+	if current == AdminEnabled {
+		ep.requestChainIDCheck()
+		ep.logger.WithFields(fields).Info("Endpoint put back into rotation by admin")
+	} else {
+		ep.logger.WithFields(fields).Warn("Endpoint taken out of rotation by admin")
+	}
+	return ep.EndpointStatus(name)
+}
+
+// ForceCircuitBreaker forces the breaker of an endpoint open or closed, see
+// CircuitBreaker.ForceState. The change is logged with actor, the identity of the caller.
+func (ep *EndpointPool) ForceCircuitBreaker(name, state, actor string) (EndpointStatus, error) {
+	endpoint, err := ep.findEndpoint(name)
+	if err != nil {
+		return EndpointStatus{}, err
+	}
+	if err := endpoint.circuitBreaker.ForceState(state); err != nil {
+		return EndpointStatus{}, err
+	}
+	ep.reportCircuitBreakerState(endpoint)
+	ep.metrics.AdminActions.WithLabelValues(ep.Network, name, "circuit_breaker_"+state).Inc()
+
+	ep.logger.WithFields(logrus.Fields{
+		"network":  ep.Network,
+		"endpoint": name,
+		"actor":    actor,
+		"state":    state,
+	}).Warn("Circuit breaker state forced by admin")
+	return ep.EndpointStatus(name)
+}
@@ -3,12 +3,14 @@ package endpoints
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"sync"
 	"time"
 
 	"github.com/pampatzoglou/chain-view/config"
+	"github.com/pampatzoglou/chain-view/internal/database"
 	"github.com/pampatzoglou/chain-view/internal/logging"
+	"github.com/pampatzoglou/chain-view/internal/metrics"
+	"github.com/pampatzoglou/chain-view/internal/redact"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
@@ -16,248 +18,434 @@ import (
 
 // Endpoint represents a single endpoint with its properties.
 type Endpoint struct {
-	Name    string
-	URL     string
-	Timeout time.Duration
-}
+	Name         string
+	URL          string
+	Timeout      time.Duration
+	Weight       int
+	Priority     int
+	MaxBatchSize int
 
-// EndpointPool holds the list of endpoints and provides pooling strategies.
-type EndpointPool struct {
-	Endpoints      []Endpoint
-	current        int
-	RetryCount     int
-	RetryBackoff   time.Duration
-	JobQueue       chan Job
 	circuitBreaker *CircuitBreaker
 	rateLimiter    *rate.Limiter
+	quota          *Quota
+	subscription   *subscription // set for ws:// and wss:// endpoints
+	auth           *endpointAuth // nil when the endpoint needs no credentials
+}
 
-	// Metrics
-	jobSuccesses      *prometheus.CounterVec
-	jobFailures       *prometheus.CounterVec
-	httpResponseCodes *prometheus.CounterVec
-	responseDuration  *prometheus.HistogramVec
+// IsWebSocket reports whether the endpoint is reached over a WebSocket subscription.
+func (e Endpoint) IsWebSocket() bool {
+	return e.subscription != nil
 }
 
-// Job represents a task to be executed by the worker.
-type Job struct {
-	Endpoint   Endpoint
-	Retries    int
-	MaxRetries int
-	ctx        context.Context
+// Default rate limit applied to endpoints that do not configure one.
+const (
+	defaultRateLimit = 1 // requests per second
+	defaultBurst     = 10
+)
+
+// defaultBackgroundRateLimit is the rate of background requests, such as ingestion and
+// balance reads, when the chain does not set background_rate_limit.
+const defaultBackgroundRateLimit = 2 // requests per second
+
+// backgroundRateLimitSettings returns the background rate limit and burst of a chain.
+func backgroundRateLimitSettings(chain config.ChainConfig) (rate.Limit, int) {
+	limit := float64(defaultBackgroundRateLimit)
+	if chain.BackgroundRateLimit > 0 {
+		limit = chain.BackgroundRateLimit
+	}
+	return rate.Limit(limit), max(1, int(limit))
 }
 
-// CircuitBreaker represents a simple circuit breaker.
-type CircuitBreaker struct {
-	state         string
-	failures      int
-	successes     int
-	failureLimit  int
-	retryDuration time.Duration
-	mu            sync.RWMutex
+// rateLimitSettings returns the rate limit and burst of an endpoint, applying the defaults.
+func rateLimitSettings(e config.EndpointConfig) (rate.Limit, int) {
+	limit, burst := rate.Limit(defaultRateLimit), defaultBurst
+	if e.RateLimit > 0 {
+		limit = rate.Limit(e.RateLimit)
+	}
+	if e.Burst > 0 {
+		burst = e.Burst
+	}
+	return limit, burst
 }
 
-// CircuitBreakerMetrics stores metrics for the circuit breaker.
-type CircuitBreakerMetrics struct {
-	Failures     int
-	Successes    int
-	CircuitState string
+// RedactedURL returns the endpoint URL with API keys and credentials masked, for logging.
+func (e Endpoint) RedactedURL() string {
+	return redact.URL(e.URL)
 }
 
-// NewCircuitBreaker creates a new CircuitBreaker.
-func NewCircuitBreaker(failureLimit int, retryDuration time.Duration) *CircuitBreaker {
-	return &CircuitBreaker{
-		state:         "closed",
-		failureLimit:  failureLimit,
-		retryDuration: retryDuration,
+// endpointSummaries describes endpoint configurations without their URL secrets, for logging.
+func endpointSummaries(endpoints []config.EndpointConfig) []logrus.Fields {
+	summaries := make([]logrus.Fields, len(endpoints))
+	for i, e := range endpoints {
+		summaries[i] = logrus.Fields{"name": e.Name, "url": redact.URL(e.URL)}
 	}
+	return summaries
 }
 
-// Allow checks if the circuit breaker allows a request.
-func (cb *CircuitBreaker) Allow() bool {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
-	return cb.state != "open"
+// endpoints returns the current endpoints. ReloadConfig replaces the slice instead of
+// modifying it, so callers may iterate the result without holding the lock.
+func (ep *EndpointPool) endpoints() []Endpoint {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	return ep.Endpoints
 }
 
-// RecordFailure records a failure and updates the state.
-func (cb *CircuitBreaker) RecordFailure() {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
+// selectionStrategy returns the current selection strategy.
+func (ep *EndpointPool) selectionStrategy() SelectionStrategy {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	return ep.strategy
+}
 
-	cb.failures++
-	if cb.failures >= cb.failureLimit {
-		cb.state = "open"
-		go func() {
-			time.Sleep(cb.retryDuration)
-			cb.mu.Lock()
-			cb.state = "half-open"
-			cb.mu.Unlock()
-		}()
-	}
+// backgroundLimiter returns the rate limiter of background requests.
+func (ep *EndpointPool) backgroundLimiter() *rate.Limiter {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	return ep.background
 }
 
-// RecordSuccess records a successful request and resets failures.
-func (cb *CircuitBreaker) RecordSuccess() {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
+// IngestionSettings returns the current block ingestion settings of the chain.
+func (ep *EndpointPool) IngestionSettings() config.IngestionConfig {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	return ep.Ingestion
+}
 
-	cb.successes++
-	cb.failures = 0
-	cb.state = "closed"
+// BalanceSettings returns the current balance tracking settings of the chain.
+func (ep *EndpointPool) BalanceSettings() config.BalanceConfig {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	return ep.Balances
 }
 
-// GetMetrics returns the current metrics of the circuit breaker.
-func (cb *CircuitBreaker) GetMetrics() CircuitBreakerMetrics {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
+// effectiveWeight returns the endpoint weight used by the weighted strategy.
+// An unset weight counts as 1.
+func (e Endpoint) effectiveWeight() int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+// buildEndpoints converts the endpoint configuration of a chain into Endpoints.
+// Credentials are resolved from their env vars and secret files here.
+func (ep *EndpointPool) buildEndpoints(chain config.ChainConfig) ([]Endpoint, error) {
+	endpoints := make([]Endpoint, len(chain.Endpoints))
+	for i, e := range chain.Endpoints {
+		auth, err := newEndpointAuth(e)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint %s: %w", e.Name, err)
+		}
+		settings := resolveCircuitBreakerSettings(chain.CircuitBreaker, e.CircuitBreaker)
+		endpoints[i] = Endpoint{
+			Name:         e.Name,
+			URL:          e.URL,
+			Timeout:      e.Timeout.Duration,
+			Weight:       e.Weight,
+			Priority:     e.Priority,
+			MaxBatchSize: e.MaxBatchSize,
+
+			circuitBreaker: NewCircuitBreaker(settings, ep.circuitBreakerTransition(e.Name)),
+			rateLimiter:    rate.NewLimiter(rateLimitSettings(e)),
+			quota:          NewQuota(e.Quota.Daily, e.Quota.Monthly),
+			auth:           auth,
+		}
+		if isWebSocketURL(e.URL) {
+			endpoints[i].subscription = ep.newSubscription(e.Name, e.URL, auth, e.Timeout.Duration, e.StaleTimeout.Duration)
+		}
+	}
+	return endpoints, nil
+}
 
-	return CircuitBreakerMetrics{
-		Failures:     cb.failures,
-		Successes:    cb.successes,
-		CircuitState: cb.state,
+// circuitBreakerTransition returns the transition hook for the breaker of the named endpoint.
+func (ep *EndpointPool) circuitBreakerTransition(name string) func(from, to string) {
+	return func(from, to string) {
+		ep.metrics.CircuitBreakerTransitions.WithLabelValues(ep.Network, name, from, to).Inc()
+		ep.metrics.CircuitBreakerState.WithLabelValues(ep.Network, name).Set(circuitStateValues[to])
+		ep.logger.WithFields(logrus.Fields{
+			"network":  ep.Network,
+			"endpoint": name,
+			"from":     from,
+			"to":       to,
+		}).Warn("Circuit breaker state changed")
 	}
 }
 
+// EndpointPool holds the list of endpoints and provides pooling strategies.
+// Endpoints and the chain settings are replaced by ReloadConfig while workers and the
+// proxy use the pool; mu guards them, and readers go through the accessors below.
+type EndpointPool struct {
+	mu sync.RWMutex
+
+	Network      string
+	ChainID      int
+	Endpoints    []Endpoint
+	Strategy     string
+	strategy     SelectionStrategy
+	RetryCount   int
+	RetryBackoff time.Duration
+	RetryPolicy  string
+	SplitBatches bool
+	MaxBlockLag  uint64
+	heads        *headTracker
+	reorgs       *reorgDetector
+
+	QuorumSize      int
+	QuorumThreshold int
+
+	FinalityPollInterval   time.Duration
+	FinalityStallThreshold time.Duration
+	finality               *finalityTracker
+	ChainIDCheckInterval   time.Duration
+	chainIDs               *chainIDTracker
+	chainIDRecheck         chan struct{}
+	background             *rate.Limiter // shared by every background request
+	Ingestion              config.IngestionConfig
+	Balances               config.BalanceConfig
+	probes                 *probeTracker
+	control                *endpointControl
+	probeIndex             int
+	runCtx                 context.Context // set once the pool is started
+	JobQueue               chan Job
+	queueMu                sync.RWMutex
+	queueClosed            bool
+	backoff                *backoff
+	logger                 *logging.Logger
+	metrics                *metrics.MetricsManager
+	checks                 *database.CheckWriter // probe history, nil when not persisted
+}
+
+// Job represents a task to be executed by the worker.
+type Job struct {
+	Endpoint   Endpoint
+	Retries    int
+	MaxRetries int
+	Attempts   []Attempt
+	ctx        context.Context
+}
+
 // CreatePools creates endpoint pools for each chain specified in the configuration.
-func CreatePools(chains []config.ChainConfig, logger *logging.Logger) ([]*EndpointPool, []error) {
+// Retry settings a chain leaves unset are taken from the global settings.
+// All pools share the collectors of mm, which must be registered once by the caller.
+func CreatePools(chains []config.ChainConfig, global config.GlobalSettings, logger *logging.Logger, mm *metrics.MetricsManager) ([]*EndpointPool, []error) {
 	var pools []*EndpointPool
 	var errors []error
 
 	for _, chain := range chains {
-		pool, err := NewEndpointPool(chain, logger)
+		pool, err := NewEndpointPool(applyGlobalSettings(chain, global), logger, mm)
 		if err != nil {
 			errors = append(errors, fmt.Errorf("failed to create endpoint pool for chain %s (ID: %d): %w", chain.Network, chain.ChainID, err))
 			continue
 		}
 		pools = append(pools, pool)
-
-		go pool.LogChainConfig(logger)
 	}
 
 	return pools, errors
 }
 
-// NewEndpointPool initializes a new EndpointPool with the given chain configuration and logger.
-func NewEndpointPool(chain config.ChainConfig, logger *logging.Logger) (*EndpointPool, error) {
-	if err := validateChainConfig(chain); err != nil {
-		return nil, err
+// applyGlobalSettings fills the retry settings of a chain from the global settings where unset.
+func applyGlobalSettings(chain config.ChainConfig, global config.GlobalSettings) config.ChainConfig {
+	if chain.RetryCount == 0 {
+		chain.RetryCount = global.MaxRetries
+	}
+	if chain.RetryBackoff.Duration == 0 {
+		chain.RetryBackoff = global.RetryBackoff
+	}
+	if chain.RetryBackoffMax.Duration == 0 {
+		chain.RetryBackoffMax = global.RetryBackoffMax
 	}
+	return chain
+}
 
-	endpoints := make([]Endpoint, len(chain.Endpoints))
-	for i, ep := range chain.Endpoints {
-		endpoints[i] = Endpoint{
-			Name:    ep.Name,
-			URL:     ep.URL,
-			Timeout: ep.Timeout.Duration,
-		}
+// NewEndpointPool initializes a new EndpointPool with the given chain configuration and logger.
+// The configuration must have passed config.Validate. Metrics are reported through mm,
+// labelled with the chain's network name.
+func NewEndpointPool(chain config.ChainConfig, logger *logging.Logger, mm *metrics.MetricsManager) (*EndpointPool, error) {
+	strategy, err := NewSelectionStrategy(chain.PoolingStrategy)
+	if err != nil {
+		return nil, err
 	}
 
 	logger.WithFields(logrus.Fields{
-		"network":   chain.Network,
-		"chain_id":  chain.ChainID,
-		"endpoints": chain.Endpoints,
+		"network":          chain.Network,
+		"chain_id":         chain.ChainID,
+		"endpoints":        endpointSummaries(chain.Endpoints),
+		"pooling_strategy": chain.PoolingStrategy,
 	}).Info("Initialized endpoint pool")
 
-	// Initialize metrics
-	jobSuccesses := prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "chainview_job_successes_total",
-		Help: "Total number of successful jobs",
-	}, []string{"chain", "endpoint"})
-	jobFailures := prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "chainview_job_failures_total",
-		Help: "Total number of failed jobs",
-	}, []string{"chain", "endpoint"})
-	httpResponseCodes := prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "chainview_http_response_codes_total",
-		Help: "Total number of HTTP response codes by endpoint",
-	}, []string{"chain", "endpoint", "code"})
-	responseDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "chainview_response_duration_seconds",
-		Help:    "Histogram of response durations by endpoint",
-		Buckets: prometheus.DefBuckets,
-	}, []string{"chain", "endpoint"})
-
-	// Register metrics with Prometheus
-	prometheus.MustRegister(jobSuccesses, jobFailures, httpResponseCodes, responseDuration)
-
-	return &EndpointPool{
-		Endpoints:         endpoints,
-		current:           0,
-		RetryCount:        chain.RetryCount,
-		RetryBackoff:      chain.RetryBackoff.Duration,
-		circuitBreaker:    NewCircuitBreaker(3, 10*time.Second),
-		rateLimiter:       rate.NewLimiter(rate.Every(time.Second), 10), // 10 requests per second
-		jobSuccesses:      jobSuccesses,
-		jobFailures:       jobFailures,
-		httpResponseCodes: httpResponseCodes,
-		responseDuration:  responseDuration,
-	}, nil
-}
-
-func validateChainConfig(chain config.ChainConfig) error {
-	if chain.Network == "" {
-		return fmt.Errorf("network name is required")
-	}
-	if chain.ChainID == 0 {
-		return fmt.Errorf("chain ID is required")
-	}
-	if len(chain.Endpoints) == 0 {
-		return fmt.Errorf("at least one endpoint is required")
-	}
-	for _, ep := range chain.Endpoints {
-		if ep.Name == "" || ep.URL == "" {
-			return fmt.Errorf("endpoint name and URL are required")
-		}
+	pool := &EndpointPool{
+		Network:                chain.Network,
+		ChainID:                chain.ChainID,
+		Strategy:               chain.PoolingStrategy,
+		strategy:               strategy,
+		RetryCount:             chain.RetryCount,
+		RetryBackoff:           chain.RetryBackoff.Duration,
+		RetryPolicy:            retryPolicyOrDefault(chain.RetryPolicy),
+		SplitBatches:           chain.SplitBatches,
+		MaxBlockLag:            chain.MaxBlockLag,
+		heads:                  newHeadTracker(),
+		reorgs:                 newReorgDetector(chain.ReorgWindow),
+		QuorumSize:             chain.Quorum.Size,
+		QuorumThreshold:        chain.Quorum.Threshold,
+		FinalityPollInterval:   chain.FinalityPollInterval.Duration,
+		FinalityStallThreshold: chain.FinalityStallThreshold.Duration,
+		finality:               newFinalityTracker(),
+		ChainIDCheckInterval:   chain.ChainIDCheckInterval.Duration,
+		chainIDs:               newChainIDTracker(),
+		chainIDRecheck:         make(chan struct{}, 1),
+		background:             rate.NewLimiter(backgroundRateLimitSettings(chain)),
+		Ingestion:              chain.Ingestion,
+		Balances:               chain.Balances,
+		probes:                 newProbeTracker(),
+		control:                newEndpointControl(),
+		metrics:                mm,
+		backoff:                newBackoff(chain.RetryBackoff.Duration, chain.RetryBackoffMax.Duration),
+		logger:                 logger,
 	}
-	return nil
+	pool.Endpoints, err = pool.buildEndpoints(chain)
+	if err != nil {
+		return nil, err
+	}
+	pool.reportEndpointInfo()
+
+	return pool, nil
 }
 
-// LogChainConfig logs the configuration for the chain.
-func (ep *EndpointPool) LogChainConfig(logger *logging.Logger) {
+// LogChainConfig logs the configuration for the chain periodically until ctx is canceled.
+func (ep *EndpointPool) LogChainConfig(ctx context.Context, logger *logging.Logger) {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := ep.endpoints()
+		endpoints := make([]logrus.Fields, len(current))
+		for i, e := range current {
+			endpoints[i] = logrus.Fields{
+				"name":     e.Name,
+				"url":      e.RedactedURL(),
+				"timeout":  e.Timeout.String(),
+				"weight":   e.Weight,
+				"priority": e.Priority,
+			}
+		}
 		logger.WithFields(logrus.Fields{
-			"endpoints": ep.Endpoints,
+			"network":   ep.Network,
+			"endpoints": endpoints,
 		}).Debug("Logging chain configuration")
 	}
 }
 
+// reportEndpointInfo exports the info metric of every endpoint of the chain, dropping removed ones.
+func (ep *EndpointPool) reportEndpointInfo() {
+	ep.metrics.EndpointInfo.DeletePartialMatch(prometheus.Labels{"chain": ep.Network})
+	for _, e := range ep.endpoints() {
+		transport := "http"
+		if e.IsWebSocket() {
+			transport = "websocket"
+		}
+		ep.metrics.EndpointInfo.WithLabelValues(ep.Network, e.Name, redact.Host(e.URL), transport).Set(1)
+	}
+}
+
+// reportEndpointState exports the info, breaker state, quota and admin state of every
+// endpoint again, for a pool whose series were deleted along with those of the pool it replaced.
+func (ep *EndpointPool) reportEndpointState() {
+	ep.reportEndpointInfo()
+	for _, e := range ep.endpoints() {
+		ep.reportCircuitBreakerState(e)
+		ep.reportQuota(e)
+		if state := ep.control.state(e.Name); state != AdminEnabled {
+			ep.metrics.EndpointAdminState.WithLabelValues(ep.Network, e.Name).Set(adminStateValues[state])
+		}
+	}
+}
+
+// deleteEndpointMetrics drops the series that describe the current state of an endpoint.
+func (ep *EndpointPool) deleteEndpointMetrics(endpoint string) {
+	labels := prometheus.Labels{"chain": ep.Network, "endpoint": endpoint}
+	for _, gauge := range []*prometheus.GaugeVec{
+		ep.metrics.CurrentBlockHeight, ep.metrics.BlockLag, ep.metrics.CircuitBreakerState,
+		ep.metrics.EndpointAdminState, ep.metrics.QuotaRemaining, ep.metrics.EndpointInfo,
+		ep.metrics.ChainIDMismatch, ep.metrics.ReportedChainID, ep.metrics.WSLastMessageAge,
+	} {
+		gauge.DeletePartialMatch(labels)
+	}
+	ep.metrics.DeleteFinalityMetrics(ep.Network, endpoint)
+}
+
 // Worker processes jobs from the job channel.
 func (ep *EndpointPool) worker(id int, jobs <-chan Job, wg *sync.WaitGroup, logger *logging.Logger) {
 	defer wg.Done()
 	for job := range jobs {
-		if err := ep.rateLimiter.Wait(job.ctx); err != nil {
+		if !ep.inRotation(job.Endpoint.Name) {
+			logger.WithFields(logrus.Fields{
+				"endpoint": job.Endpoint.Name,
+			}).Debug("Endpoint is out of rotation, skipping job")
+			continue
+		}
+
+		if err := job.Endpoint.rateLimiter.Wait(job.ctx); err != nil {
+			if job.ctx.Err() != nil {
+				continue
+			}
 			logger.WithError(err).Warn("Rate limit exceeded, skipping job")
+			ep.recordSkippedCheck(job.Endpoint, ErrorClassLocalRateLimit)
+			continue
+		}
+
+		if !job.Endpoint.circuitBreaker.Allow() {
+			logger.WithFields(logrus.Fields{
+				"endpoint": job.Endpoint.Name,
+			}).Warn("Circuit breaker is open, skipping job")
+			ep.recordSkippedCheck(job.Endpoint, ErrorClassCircuitOpen)
 			continue
 		}
 
-		if !ep.circuitBreaker.Allow() {
-			logger.Warn("Circuit breaker is open, skipping job")
+		// WebSocket probes read the subscribed head and do not spend provider quota
+		if !job.Endpoint.IsWebSocket() && !ep.consumeQuota(job.Endpoint) {
+			job.Endpoint.circuitBreaker.Release()
+			ep.recordSkippedCheck(job.Endpoint, ErrorClassQuotaExhausted)
 			continue
 		}
 
 		start := time.Now()
-		err := ep.fetchData(job.ctx, job.Endpoint)
-		duration := time.Since(start).Seconds()
+		head, err := ep.fetchData(job.ctx, job.Endpoint)
+		elapsed := time.Since(start)
+		if ep.abandoned(job.ctx, job.Endpoint, err) {
+			continue
+		}
 
-		ep.responseDuration.WithLabelValues(job.Endpoint.Name, job.Endpoint.URL).Observe(duration)
+		ep.recordOutcome(job.Endpoint, elapsed, err)
+		attempt := Attempt{Endpoint: job.Endpoint.Name, At: start, Duration: elapsed, Err: err}
+		ep.probes.recordProbe(attempt)
+		ep.recordCheck(job.Endpoint, attempt, head)
+		job.Attempts = append(job.Attempts, attempt)
 
 		if err == nil {
-			ep.jobSuccesses.WithLabelValues(job.Endpoint.Name, job.Endpoint.URL).Inc()
-			ep.circuitBreaker.RecordSuccess()
-			logger.Infof("Worker %d successfully processed job for %s", id, job.Endpoint.URL)
+			ep.metrics.JobSuccesses.WithLabelValues(ep.Network, job.Endpoint.Name).Inc()
+			ep.recordBlock(job.Endpoint.Name, head)
+			logger.WithFields(logrus.Fields{
+				"block_height": head.Number,
+				"attempts":     len(job.Attempts),
+			}).Infof("Worker %d successfully processed job for %s", id, job.Endpoint.Name)
 		} else {
-			ep.jobFailures.WithLabelValues(job.Endpoint.Name, job.Endpoint.URL).Inc()
-			ep.circuitBreaker.RecordFailure()
-			logger.WithError(err).Errorf("Worker %d failed to fetch data from %s", id, job.Endpoint.URL)
+			ep.metrics.JobFailures.WithLabelValues(ep.Network, job.Endpoint.Name).Inc()
+			logger.WithError(err).WithFields(logrus.Fields{
+				"attempts": len(job.Attempts),
+			}).Errorf("Worker %d failed to fetch data from %s", id, job.Endpoint.Name)
 			if job.Retries < job.MaxRetries {
-				job.Retries++
-				backoff := time.Duration(job.Retries*job.Retries) * time.Second // Exponential backoff
-				logger.Infof("Retrying job for %s (%d/%d) after %s", job.Endpoint.URL, job.Retries, job.MaxRetries, backoff)
-				time.Sleep(backoff)
-				ep.JobQueue <- job
+				ep.scheduleRetry(job)
+			} else if len(job.Attempts) > 1 {
+				logger.WithFields(logrus.Fields{
+					"network":  ep.Network,
+					"attempts": attemptSummary(job.Attempts),
+				}).Error("Job failed after exhausting retries")
 			}
 		}
 	}
@@ -272,46 +460,113 @@ func (ep *EndpointPool) StartWorkers(ctx context.Context, numWorkers int, logger
 	}
 
 	<-ctx.Done()
-	close(ep.JobQueue)
+	ep.closeQueue()
 	wg.Wait()
 }
 
-// FetchData fetches data from an endpoint and handles timeouts.
-func (ep *EndpointPool) fetchData(ctx context.Context, endpoint Endpoint) error {
-	client := &http.Client{
-		Timeout: endpoint.Timeout,
+// FetchData probes an endpoint for its latest block.
+// WebSocket endpoints are not polled; their latest subscribed head is used instead.
+func (ep *EndpointPool) fetchData(ctx context.Context, endpoint Endpoint) (blockHead, error) {
+	if endpoint.IsWebSocket() {
+		return endpoint.subscription.latestHead()
 	}
+	return ep.latestBlock(ctx, endpoint)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", endpoint.URL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+// GetNextEndpoint returns the next endpoint chosen by the pool's selection strategy.
+// Endpoints whose circuit breaker is open, whose quota is used up or that lag behind
+// the chain head are skipped unless no other endpoint is available. Endpoints serving
+// another chain or taken out of rotation by an operator are only returned when no
+// endpoint of the pool is in rotation.
+func (ep *EndpointPool) GetNextEndpoint() Endpoint {
+	candidates := ep.availableEndpoints()
+	if len(candidates) == 0 {
+		for _, e := range ep.endpoints() {
+			if ep.inRotation(e.Name) {
+				candidates = append(candidates, e)
+			}
+		}
 	}
+	if len(candidates) == 0 {
+		candidates = ep.endpoints()
+	}
+	return candidates[ep.selectionStrategy().Select(candidates)]
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("HTTP request failed: %w", err)
+// availableEndpoints returns the endpoints that currently accept requests.
+func (ep *EndpointPool) availableEndpoints() []Endpoint {
+	current := ep.endpoints()
+	candidates := make([]Endpoint, 0, len(current))
+	for _, e := range current {
+		if ep.isAvailable(e) {
+			candidates = append(candidates, e)
+		}
 	}
-	defer resp.Body.Close()
+	return candidates
+}
 
-	ep.httpResponseCodes.WithLabelValues(endpoint.Name, endpoint.URL, fmt.Sprintf("%d", resp.StatusCode)).Inc()
+// isAvailable reports whether an endpoint currently accepts requests.
+func (ep *EndpointPool) isAvailable(e Endpoint) bool {
+	if ep.heads.isDegraded(e.Name) {
+		return false
+	}
+	return ep.isProbeable(e)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("non-200 response: %d", resp.StatusCode)
+// isProbeable reports whether an endpoint can be probed. Unlike isAvailable it
+// ignores block lag, so that degraded endpoints are still checked and can recover.
+// Endpoints out of rotation are not probed; quarantined ones are only asked for their chain ID.
+func (ep *EndpointPool) isProbeable(e Endpoint) bool {
+	if !ep.inRotation(e.Name) {
+		return false
 	}
-	return nil
+	if e.IsWebSocket() && !e.subscription.healthy() {
+		return false
+	}
+	return e.circuitBreaker.Ready() && !e.quota.Exhausted()
 }
 
-// GetNextEndpoint returns the next endpoint using a round-robin strategy.
-func (ep *EndpointPool) GetNextEndpoint() Endpoint {
-	ep.current = (ep.current + 1) % len(ep.Endpoints)
-	return ep.Endpoints[ep.current]
+// nextProbeEndpoint returns the next endpoint to probe, cycling through every endpoint
+// in rotation so that endpoints the strategy does not select are still monitored.
+// Disabled, drained and quarantined endpoints are not probed.
+func (ep *EndpointPool) nextProbeEndpoint() (Endpoint, bool) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	for range ep.Endpoints {
+		ep.probeIndex = (ep.probeIndex + 1) % len(ep.Endpoints)
+		if e := ep.Endpoints[ep.probeIndex]; ep.inRotation(e.Name) {
+			return e, true
+		}
+	}
+	return Endpoint{}, false
 }
 
-// ProcessEndpoints starts the processing of endpoints concurrently.
+// startSubscriptions starts the WebSocket subscriptions of the pool's endpoints.
+// ctx is kept so that endpoints added by a reload are started with it as well.
+func (ep *EndpointPool) startSubscriptions(ctx context.Context) {
+	ep.mu.Lock()
+	ep.runCtx = ctx
+	current := ep.Endpoints
+	ep.mu.Unlock()
+
+	for _, e := range current {
+		if e.IsWebSocket() {
+			e.subscription.start(ctx)
+		}
+	}
+}
+
+// ProcessEndpoints starts the processing of endpoints concurrently. When ctx is canceled
+// it returns after the workers have finished their in-flight jobs.
 func (ep *EndpointPool) ProcessEndpoints(ctx context.Context, numWorkers int, logger *logging.Logger) {
-	ep.JobQueue = make(chan Job, len(ep.Endpoints))
+	ep.JobQueue = make(chan Job, len(ep.endpoints()))
 
-	go ep.StartWorkers(ctx, numWorkers, logger)
+	workersDone := make(chan struct{})
+	go func() {
+		defer close(workersDone)
+		ep.StartWorkers(ctx, numWorkers, logger)
+	}()
+	ep.startSubscriptions(ctx)
 
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
@@ -320,19 +575,63 @@ func (ep *EndpointPool) ProcessEndpoints(ctx context.Context, numWorkers int, lo
 		select {
 		case <-ctx.Done():
 			logger.Info("Stopping endpoint processing due to context cancellation")
+			<-workersDone
 			return
 		case <-ticker.C:
-			endpoint := ep.GetNextEndpoint()
-			select {
-			case ep.JobQueue <- Job{Endpoint: endpoint, Retries: 0, MaxRetries: ep.RetryCount, ctx: ctx}:
-			default:
+			endpoint, ok := ep.nextProbeEndpoint()
+			if !ok {
+				continue
+			}
+			ep.mu.RLock()
+			maxRetries := ep.RetryCount
+			ep.mu.RUnlock()
+			if !ep.enqueue(Job{Endpoint: endpoint, Retries: 0, MaxRetries: maxRetries, ctx: ctx}) {
 				logger.Warn("Job queue is full, skipping job")
 			}
 		}
 	}
 }
 
-// LogCircuitBreakerMetrics logs the circuit breaker metrics periodically.
+// consumeQuota uses one request of the endpoint quota, exports the remaining
+// requests and reports whether the request may be sent.
+func (ep *EndpointPool) consumeQuota(endpoint Endpoint) bool {
+	return ep.consumeQuotaN(endpoint, 1)
+}
+
+// consumeQuotaN is consumeQuota for a request that counts as n, such as a batch.
+func (ep *EndpointPool) consumeQuotaN(endpoint Endpoint, n int) bool {
+	ok := endpoint.quota.ConsumeN(int64(n))
+	usage := ep.reportQuota(endpoint)
+
+	fields := logrus.Fields{
+		"network":           ep.Network,
+		"endpoint":          endpoint.Name,
+		"daily_remaining":   usage.DailyRemaining,
+		"monthly_remaining": usage.MonthlyRemaining,
+	}
+	if !ok {
+		ep.logger.WithFields(fields).Warn("Endpoint quota exhausted, skipping request")
+		return false
+	}
+	if endpoint.quota.NeedsWarning() {
+		ep.logger.WithFields(fields).Warn("Endpoint quota running low")
+	}
+	return true
+}
+
+// reportQuota exports the remaining requests of the endpoint quota and returns its usage.
+func (ep *EndpointPool) reportQuota(endpoint Endpoint) QuotaUsage {
+	usage := endpoint.quota.Usage()
+	if usage.DailyRemaining >= 0 {
+		ep.metrics.QuotaRemaining.WithLabelValues(ep.Network, endpoint.Name, QuotaDaily).Set(float64(usage.DailyRemaining))
+	}
+	if usage.MonthlyRemaining >= 0 {
+		ep.metrics.QuotaRemaining.WithLabelValues(ep.Network, endpoint.Name, QuotaMonthly).Set(float64(usage.MonthlyRemaining))
+	}
+	return usage
+}
+
+// LogCircuitBreakerMetrics logs the circuit breaker metrics of every endpoint periodically.
 func (ep *EndpointPool) LogCircuitBreakerMetrics(ctx context.Context, logger *logging.Logger) {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
@@ -342,36 +641,148 @@ func (ep *EndpointPool) LogCircuitBreakerMetrics(ctx context.Context, logger *lo
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			metrics := ep.circuitBreaker.GetMetrics()
-			logger.WithFields(logrus.Fields{
-				"failures":      metrics.Failures,
-				"successes":     metrics.Successes,
-				"circuit_state": metrics.CircuitState,
-			}).Info("Circuit Breaker Metrics")
+			for _, endpoint := range ep.endpoints() {
+				stats := ep.reportCircuitBreakerState(endpoint)
+				logger.WithFields(logrus.Fields{
+					"network":       ep.Network,
+					"endpoint":      endpoint.Name,
+					"failures":      stats.Failures,
+					"successes":     stats.Successes,
+					"circuit_state": stats.CircuitState,
+				}).Info("Circuit Breaker Metrics")
+			}
 		}
 	}
 }
 
-// ReloadConfig allows for dynamic configuration updates.
+// reportCircuitBreakerState exports the breaker state of an endpoint and returns its metrics.
+func (ep *EndpointPool) reportCircuitBreakerState(endpoint Endpoint) CircuitBreakerMetrics {
+	stats := endpoint.circuitBreaker.GetMetrics()
+	ep.metrics.CircuitBreakerState.WithLabelValues(ep.Network, endpoint.Name).Set(circuitStateValues[stats.CircuitState])
+	return stats
+}
+
+// ReloadConfig applies a new, validated configuration to the pool. Everything is built
+// before the pool changes, then swapped in under the pool lock, so workers and proxied
+// requests see either the old or the new endpoints. Jobs already running keep the endpoint
+// they were given. Breaker state, rate limiters, quota usage and live WebSocket
+// subscriptions of endpoints that survive the reload are carried over.
 func (ep *EndpointPool) ReloadConfig(newConfig config.ChainConfig) error {
-	if err := validateChainConfig(newConfig); err != nil {
+	strategy, err := NewSelectionStrategy(newConfig.PoolingStrategy)
+	if err != nil {
+		return err
+	}
+
+	newEndpoints, err := ep.buildEndpoints(newConfig)
+	if err != nil {
 		return err
 	}
 
+	ep.mu.Lock()
+
+	// Keep the breaker state and quota usage of endpoints that survive the reload, applying
+	// their new limits and breaker thresholds
+	existing := make(map[string]Endpoint, len(ep.Endpoints))
+	for _, e := range ep.Endpoints {
+		existing[e.Name] = e
+	}
+	kept := make(map[*subscription]bool)
+	for i, e := range newConfig.Endpoints {
+		old, ok := existing[e.Name]
+		if !ok {
+			continue
+		}
+		limit, burst := rateLimitSettings(e)
+		old.rateLimiter.SetLimit(limit)
+		old.rateLimiter.SetBurst(burst)
+		old.quota.SetLimits(e.Quota.Daily, e.Quota.Monthly)
+		old.circuitBreaker.SetSettings(resolveCircuitBreakerSettings(newConfig.CircuitBreaker, e.CircuitBreaker))
+
+		newEndpoints[i].circuitBreaker = old.circuitBreaker
+		newEndpoints[i].rateLimiter = old.rateLimiter
+		newEndpoints[i].quota = old.quota
+		if old.IsWebSocket() && newEndpoints[i].IsWebSocket() && old.URL == e.URL {
+			old.subscription.setAuth(newEndpoints[i].auth) // Used from the next reconnect
+			newEndpoints[i].subscription = old.subscription
+			kept[old.subscription] = true
+		}
+	}
+
 	ep.RetryCount = newConfig.RetryCount
 	ep.RetryBackoff = newConfig.RetryBackoff.Duration
+	ep.RetryPolicy = retryPolicyOrDefault(newConfig.RetryPolicy)
+	ep.SplitBatches = newConfig.SplitBatches
+	ep.MaxBlockLag = newConfig.MaxBlockLag
+	ep.QuorumSize = newConfig.Quorum.Size
+	ep.QuorumThreshold = newConfig.Quorum.Threshold
+	ep.FinalityPollInterval = newConfig.FinalityPollInterval.Duration
+	ep.FinalityStallThreshold = newConfig.FinalityStallThreshold.Duration
+	ep.ChainIDCheckInterval = newConfig.ChainIDCheckInterval.Duration
+	ep.Ingestion = newConfig.Ingestion
+	backgroundLimit, backgroundBurst := backgroundRateLimitSettings(newConfig)
+	ep.background.SetLimit(backgroundLimit)
+	ep.background.SetBurst(backgroundBurst)
+	ep.Balances = newConfig.Balances
+	ep.backoff = newBackoff(newConfig.RetryBackoff.Duration, newConfig.RetryBackoffMax.Duration)
+
+	oldEndpoints := ep.Endpoints
+	ep.Endpoints = newEndpoints
+	ep.probeIndex = 0
+	ep.Strategy = newConfig.PoolingStrategy
+	ep.strategy = strategy // Reset the selection state
+	runCtx := ep.runCtx
+	ep.mu.Unlock()
+
+	names := make(map[string]bool, len(newEndpoints))
+	for _, e := range newEndpoints {
+		names[e.Name] = true
+	}
+	ep.heads.retain(names)
+	ep.reorgs.retain(names)
+	ep.probes.retain(names)
+	ep.control.retain(names)
+	ep.finality.retain(names)
+	for _, e := range oldEndpoints {
+		if !names[e.Name] {
+			ep.forgetHead(e.Name)
+			ep.deleteEndpointMetrics(e.Name)
+		}
+	}
 
-	newEndpoints := make([]Endpoint, len(newConfig.Endpoints))
-	for i, epConfig := range newConfig.Endpoints {
-		newEndpoints[i] = Endpoint{
-			Name:    epConfig.Name,
-			URL:     epConfig.URL,
-			Timeout: epConfig.Timeout.Duration,
+	// Endpoints whose URL changed are verified again before they can be quarantined
+	verified := make(map[string]bool, len(newEndpoints))
+	for _, e := range newEndpoints {
+		if old, ok := existing[e.Name]; ok && old.URL == e.URL {
+			verified[e.Name] = true
+		}
+	}
+	ep.chainIDs.retain(verified)
+	for _, e := range oldEndpoints {
+		if !verified[e.Name] {
+			ep.metrics.ChainIDMismatch.DeleteLabelValues(ep.Network, e.Name)
+			ep.metrics.ReportedChainID.DeleteLabelValues(ep.Network, e.Name)
 		}
 	}
+	ep.requestChainIDCheck()
 
-	ep.Endpoints = newEndpoints
-	ep.current = 0 // Reset the current endpoint index
+	// Stop the subscriptions of removed endpoints and start those of new ones
+	for _, e := range oldEndpoints {
+		if e.IsWebSocket() && !kept[e.subscription] {
+			e.subscription.stop()
+		}
+	}
+	if runCtx != nil {
+		for _, e := range newEndpoints {
+			if e.IsWebSocket() && !kept[e.subscription] {
+				e.subscription.start(runCtx)
+			}
+		}
+	}
+	ep.reportEndpointInfo()
 
+	ep.logger.WithFields(logrus.Fields{
+		"network":   ep.Network,
+		"endpoints": endpointSummaries(newConfig.Endpoints),
+	}).Info("Reloaded endpoint pool configuration")
 	return nil
 }
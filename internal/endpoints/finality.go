@@ -0,0 +1,170 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Block tags polled for finality tracking.
+const (
+	blockTagFinalized = "finalized"
+	blockTagSafe      = "safe"
+)
+
+// defaultFinalityPollInterval is used when the chain does not set finality_poll_interval.
+const defaultFinalityPollInterval = 30 * time.Second
+
+// finalityTracker remembers when the finalized block of each endpoint last advanced.
+type finalityTracker struct {
+	mu         sync.Mutex
+	finalized  map[string]uint64
+	advancedAt map[string]time.Time
+	stalled    map[string]bool
+}
+
+// newFinalityTracker creates an empty finalityTracker.
+func newFinalityTracker() *finalityTracker {
+	return &finalityTracker{
+		finalized:  make(map[string]uint64),
+		advancedAt: make(map[string]time.Time),
+		stalled:    make(map[string]bool),
+	}
+}
+
+// update records the finalized block of an endpoint and returns how long it has not
+// advanced, whether that exceeds threshold, and whether the stalled state changed.
+// A threshold of zero disables stall detection.
+func (t *finalityTracker) update(endpoint string, finalized uint64, now time.Time, threshold time.Duration) (time.Duration, bool, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if prev, ok := t.finalized[endpoint]; !ok || finalized > prev {
+		t.finalized[endpoint] = finalized
+		t.advancedAt[endpoint] = now
+	}
+
+	since := now.Sub(t.advancedAt[endpoint])
+	stalled := threshold > 0 && since > threshold
+	changed := stalled != t.stalled[endpoint]
+	t.stalled[endpoint] = stalled
+	return since, stalled, changed
+}
+
+// retain forgets every endpoint that is not in names.
+func (t *finalityTracker) retain(names map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for name := range t.finalized {
+		if !names[name] {
+			delete(t.finalized, name)
+			delete(t.advancedAt, name)
+			delete(t.stalled, name)
+		}
+	}
+}
+
+// blockNumberByTag fetches the number of the block with the given tag, such as finalized or safe.
+func (ep *EndpointPool) blockNumberByTag(ctx context.Context, endpoint Endpoint, tag string) (uint64, error) {
+	var block struct {
+		Number string `json:"number"`
+	}
+	if err := ep.callRPC(ctx, endpoint, "eth_getBlockByNumber", []interface{}{tag, false}, &block); err != nil {
+		return 0, err
+	}
+	number, err := ParseHexUint64(block.Number)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s block number: %w", tag, err)
+	}
+	return number, nil
+}
+
+// TrackFinality polls the finalized and safe blocks of every endpoint until ctx is canceled.
+// A poll interval changed by a reload takes effect after the next poll.
+func (ep *EndpointPool) TrackFinality(ctx context.Context) {
+	interval := ep.finalityPollInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, endpoint := range ep.endpoints() {
+				if ep.isProbeable(endpoint) {
+					ep.checkFinality(ctx, endpoint)
+				}
+			}
+			if next := ep.finalityPollInterval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+		}
+	}
+}
+
+// finalityPollInterval returns the configured poll interval, applying the default.
+func (ep *EndpointPool) finalityPollInterval() time.Duration {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	if ep.FinalityPollInterval <= 0 {
+		return defaultFinalityPollInterval
+	}
+	return ep.FinalityPollInterval
+}
+
+// checkFinality polls the finalized and safe blocks of one endpoint and updates the metrics.
+// Failures are logged but not counted against the circuit breaker, because some chains
+// do not support the finalized and safe tags.
+func (ep *EndpointPool) checkFinality(ctx context.Context, endpoint Endpoint) {
+	fields := logrus.Fields{
+		"network":  ep.Network,
+		"endpoint": endpoint.Name,
+	}
+
+	var heights [2]uint64
+	for i, tag := range []string{blockTagFinalized, blockTagSafe} {
+		if err := endpoint.rateLimiter.Wait(ctx); err != nil {
+			return
+		}
+		if !endpoint.IsWebSocket() && !ep.consumeQuota(endpoint) {
+			return
+		}
+		height, err := ep.blockNumberByTag(ctx, endpoint, tag)
+		if err != nil {
+			ep.logger.WithError(err).WithFields(fields).Debugf("Failed to fetch %s block", tag)
+			return
+		}
+		heights[i] = height
+	}
+	finalized, safe := heights[0], heights[1]
+
+	latest, ok := ep.heads.head(endpoint.Name)
+	if !ok || latest < finalized {
+		latest = finalized
+	}
+	ep.metrics.UpdateMetrics(ep.Network, endpoint.Name, float64(finalized), float64(latest))
+	ep.metrics.UpdateFinalityMetrics(ep.Network, endpoint.Name, float64(safe), float64(latest-finalized))
+
+	ep.mu.RLock()
+	threshold := ep.FinalityStallThreshold
+	ep.mu.RUnlock()
+
+	since, stalled, changed := ep.finality.update(endpoint.Name, finalized, time.Now(), threshold)
+	ep.metrics.SetFinalityStalled(ep.Network, endpoint.Name, stalled)
+	if !changed {
+		return
+	}
+	fields["finalized"] = finalized
+	fields["since_last_advance"] = since.Round(time.Second).String()
+	if stalled {
+		ep.logger.WithFields(fields).Error("Finality stalled: finalized block has not advanced within the threshold")
+	} else {
+		ep.logger.WithFields(fields).Info("Finality resumed")
+	}
+}
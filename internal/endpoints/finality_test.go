@@ -0,0 +1,113 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFinalityTrackerStall(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	type poll struct {
+		after     time.Duration // since start
+		finalized uint64
+	}
+	tests := []struct {
+		name        string
+		threshold   time.Duration
+		polls       []poll
+		wantStalled bool
+		wantChanged bool
+		wantSince   time.Duration
+	}{
+		{
+			name:      "advancing",
+			threshold: time.Minute,
+			polls:     []poll{{0, 100}, {time.Minute, 110}, {2 * time.Minute, 120}},
+		},
+		{
+			name:      "within the threshold",
+			threshold: time.Minute,
+			polls:     []poll{{0, 100}, {time.Minute, 100}},
+			wantSince: time.Minute,
+		},
+		{
+			name:        "stalled",
+			threshold:   time.Minute,
+			polls:       []poll{{0, 100}, {time.Minute, 100}, {2 * time.Minute, 100}},
+			wantStalled: true,
+			wantChanged: true,
+			wantSince:   2 * time.Minute,
+		},
+		{
+			name:        "still stalled",
+			threshold:   time.Minute,
+			polls:       []poll{{0, 100}, {2 * time.Minute, 100}, {3 * time.Minute, 100}},
+			wantStalled: true,
+			wantSince:   3 * time.Minute,
+		},
+		{
+			name:        "resumed",
+			threshold:   time.Minute,
+			polls:       []poll{{0, 100}, {2 * time.Minute, 100}, {3 * time.Minute, 101}},
+			wantChanged: true,
+		},
+		{
+			name:      "going backwards is no progress",
+			threshold: time.Minute,
+			polls:     []poll{{0, 100}, {30 * time.Second, 90}},
+			wantSince: 30 * time.Second,
+		},
+		{
+			name:      "detection disabled",
+			polls:     []poll{{0, 100}, {time.Hour, 100}},
+			wantSince: time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newFinalityTracker()
+			var since time.Duration
+			var stalled, changed bool
+			for _, p := range tt.polls {
+				since, stalled, changed = tracker.update("a", p.finalized, start.Add(p.after), tt.threshold)
+			}
+			if since != tt.wantSince || stalled != tt.wantStalled || changed != tt.wantChanged {
+				t.Fatalf("update = %s/%v/%v, want %s/%v/%v", since, stalled, changed, tt.wantSince, tt.wantStalled, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestCheckFinalityExportsBlocks(t *testing.T) {
+	blocks := map[string]string{blockTagFinalized: "0x64", blockTagSafe: "0x6e"}
+	pool := newTestServerPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		tag, _ := req.Params[0].(string)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"` + blocks[tag] + `"}}`))
+	}))
+	pool.recordHead("provider", 130)
+
+	pool.checkFinality(context.Background(), pool.endpoints()[0])
+
+	m := pool.metrics
+	for name, series := range map[string]struct {
+		value, want float64
+	}{
+		"finalized_blocks": {testutil.ToFloat64(m.FinalizedBlocks.WithLabelValues(pool.Network, "provider")), 100},
+		"safe_blocks":      {testutil.ToFloat64(m.SafeBlocks.WithLabelValues(pool.Network, "provider")), 110},
+		"finality_gap":     {testutil.ToFloat64(m.FinalityGap.WithLabelValues(pool.Network, "provider")), 30},
+		"finality_stalled": {testutil.ToFloat64(m.FinalityStalled.WithLabelValues(pool.Network, "provider")), 0},
+	} {
+		if series.value != series.want {
+			t.Errorf("%s = %v, want %v", name, series.value, series.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrNoEndpointAvailable is returned when no endpoint of a pool could answer a request.
+var ErrNoEndpointAvailable = errors.New("no endpoint available")
+
+// ForwardResult is the outcome of a JSON-RPC payload forwarded through the pool.
+type ForwardResult struct {
+	Body     []byte    // raw response of the endpoint that answered
+	Endpoint string    // name of the endpoint that answered
+	Attempts []Attempt // every endpoint that was tried, in order
+}
+
+// Forward sends a raw JSON-RPC payload to an endpoint chosen by the pool's strategy.
+// Endpoints that are rate limited, out of quota, have an open breaker or fail the
+// request are skipped, so the caller only sees an error when every endpoint failed.
+// JSON-RPC error objects are part of a valid response and do not cause failover.
+func (ep *EndpointPool) Forward(ctx context.Context, payload []byte) (*ForwardResult, error) {
+	return ep.forward(ctx, payload, ep.acquire)
+}
+
+// forward sends a payload with failover, using acquire to admit each endpoint before it is tried.
+func (ep *EndpointPool) forward(ctx context.Context, payload []byte, acquire func(Endpoint) bool) (*ForwardResult, error) {
+	result := &ForwardResult{}
+	tried := make(map[string]bool, len(ep.endpoints()))
+
+	for {
+		endpoint, ok := ep.nextUntried(tried)
+		if !ok {
+			break
+		}
+		tried[endpoint.Name] = true
+
+		if !acquire(endpoint) {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			continue
+		}
+
+		start := time.Now()
+		status, body, err := ep.postRPC(ctx, endpoint, payload)
+		if err == nil && status != http.StatusOK {
+			err = &HTTPStatusError{StatusCode: status}
+		}
+		if err == nil && !json.Valid(body) {
+			err = fmt.Errorf("invalid JSON response")
+		}
+		elapsed := time.Since(start)
+
+		result.Attempts = append(result.Attempts, Attempt{Endpoint: endpoint.Name, At: start, Duration: elapsed, Err: err})
+		if ep.abandoned(ctx, endpoint, err) {
+			return result, ctx.Err()
+		}
+		ep.recordOutcome(endpoint, elapsed, err)
+
+		if err == nil {
+			result.Body = body
+			result.Endpoint = endpoint.Name
+			return result, nil
+		}
+	}
+
+	return result, fmt.Errorf("%w for %s after %d attempts", ErrNoEndpointAvailable, ep.Network, len(result.Attempts))
+}
+
+// Call sends a background JSON-RPC request through the pool, with the same failover as
+// Forward, and decodes the result into result. A JSON-RPC error object is returned as an
+// *RPCError. Background requests are limited by the chain's background_rate_limit and wait
+// for endpoint rate limit tokens instead of skipping endpoints, so they neither take more
+// than their share from proxied requests nor fail when they arrive in bursts.
+func (ep *EndpointPool) Call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	payload, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	if err := ep.backgroundLimiter().Wait(ctx); err != nil {
+		return err
+	}
+	forwarded, err := ep.forward(ctx, payload, func(endpoint Endpoint) bool {
+		return ep.acquireWait(ctx, endpoint)
+	})
+	if err != nil {
+		return err
+	}
+	var rpcResp rpcResponse
+	if err := json.Unmarshal(forwarded.Body, &rpcResp); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", forwarded.Endpoint, err)
+	}
+	return decodeResult(rpcResp, method, result)
+}
+
+// nextUntried selects the next available HTTP endpoint that is not in tried.
+// WebSocket endpoints only serve subscriptions and internal calls, so raw payloads are not forwarded to them.
+func (ep *EndpointPool) nextUntried(tried map[string]bool) (Endpoint, bool) {
+	var candidates []Endpoint
+	for _, e := range ep.availableEndpoints() {
+		if !tried[e.Name] && !e.IsWebSocket() {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		return Endpoint{}, false
+	}
+	return candidates[ep.selectionStrategy().Select(candidates)], true
+}
+
+// acquire takes a rate limit token, a circuit breaker slot and a quota unit for an
+// immediate request and reports whether the endpoint may be used. The breaker is checked
+// first so no token is spent on an endpoint it rejects, and a breaker slot taken for a
+// request that cannot be sent is released again.
+func (ep *EndpointPool) acquire(endpoint Endpoint) bool {
+	if !endpoint.circuitBreaker.Ready() {
+		return false
+	}
+	if !endpoint.rateLimiter.Allow() {
+		return false
+	}
+	if !endpoint.circuitBreaker.Allow() {
+		return false
+	}
+	if !ep.consumeQuota(endpoint) {
+		endpoint.circuitBreaker.Release()
+		return false
+	}
+	return true
+}
+
+// acquireWait is acquire for background requests: it waits for a rate limit token
+// instead of skipping the endpoint when none is left.
+func (ep *EndpointPool) acquireWait(ctx context.Context, endpoint Endpoint) bool {
+	return ep.acquireWaitN(ctx, endpoint, 1)
+}
+
+// acquireWaitN is acquireWait for a request that counts as n, such as a batch of n
+// elements, taking n rate limit tokens and n quota units. It gives up at once when the
+// tokens would not be available before the deadline of ctx.
+func (ep *EndpointPool) acquireWaitN(ctx context.Context, endpoint Endpoint, n int) bool {
+	if !endpoint.circuitBreaker.Ready() {
+		return false
+	}
+	if err := endpoint.rateLimiter.WaitN(ctx, n); err != nil {
+		return false
+	}
+	if !endpoint.circuitBreaker.Allow() {
+		return false
+	}
+	if !ep.consumeQuotaN(endpoint, n) {
+		endpoint.circuitBreaker.Release()
+		return false
+	}
+	return true
+}
+
+// abandoned reports whether a request failed because its context was canceled. The
+// endpoint is not to blame then, so the outcome must not reach the breaker or the
+// strategy; the half-open trial slot the request held is released instead.
+func (ep *EndpointPool) abandoned(ctx context.Context, endpoint Endpoint, err error) bool {
+	if err == nil || ctx.Err() == nil {
+		return false
+	}
+	endpoint.circuitBreaker.Release()
+	return true
+}
+
+// recordOutcome feeds the result of a request into the strategy, the circuit breaker and the metrics.
+func (ep *EndpointPool) recordOutcome(endpoint Endpoint, elapsed time.Duration, err error) {
+	ep.selectionStrategy().Observe(endpoint, elapsed, err)
+	ep.metrics.ResponseDuration.WithLabelValues(ep.Network, endpoint.Name).Observe(elapsed.Seconds())
+
+	if err == nil {
+		ep.probes.observeLatency(endpoint.Name, elapsed)
+		endpoint.circuitBreaker.RecordSuccess()
+	} else {
+		endpoint.circuitBreaker.RecordFailure()
+	}
+	ep.reportCircuitBreakerState(endpoint)
+}
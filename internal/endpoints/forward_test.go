@@ -0,0 +1,77 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+func TestCallWaitsForRateLimitTokens(t *testing.T) {
+	pool := newTestServerPool(t, rpcResult(`"0x1"`), func(chain *config.ChainConfig) {
+		chain.BackgroundRateLimit = 1000
+		chain.Endpoints[0].RateLimit = 200
+		chain.Endpoints[0].Burst = 2
+	})
+
+	// More calls than the burst, as when receipts are fetched per transaction
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		var result string
+		if err := pool.Call(ctx, "eth_blockNumber", nil, &result); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+}
+
+func TestCallHasItsOwnRateBudget(t *testing.T) {
+	pool := newTestServerPool(t, nil, func(chain *config.ChainConfig) {
+		chain.BackgroundRateLimit = 1
+	})
+
+	// With the background burst spent, the next call waits past the deadline
+	pool.backgroundLimiter().Allow()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var result string
+	if err := pool.Call(ctx, "eth_blockNumber", nil, &result); err == nil {
+		t.Fatal("call exceeded the background rate limit")
+	}
+	endpoint := pool.endpoints()[0]
+	if tokens := endpoint.rateLimiter.Tokens(); tokens < float64(defaultBurst)-1 {
+		t.Fatalf("background call spent proxy tokens: %v left", tokens)
+	}
+}
+
+func TestForwardIgnoresCanceledRequests(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	pool := newTestServerPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}), withTrialBreaker)
+	endpoint := pool.endpoints()[0]
+	clock := &fakeClock{t: time.Now()}
+	endpoint.circuitBreaker.now = clock.now
+	endpoint.circuitBreaker.RecordFailure()
+	clock.advance(time.Hour)
+
+	// The client goes away while the trial request is in flight
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Forward(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`)); err == nil {
+		t.Fatal("Forward succeeded after the context was canceled")
+	}
+
+	if state := endpoint.circuitBreaker.GetMetrics().CircuitState; state != StateHalfOpen {
+		t.Fatalf("state = %s, want %s", state, StateHalfOpen)
+	}
+	if !endpoint.circuitBreaker.Ready() {
+		t.Fatal("trial slot of the canceled request was not released")
+	}
+}
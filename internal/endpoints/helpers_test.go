@@ -0,0 +1,103 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/pampatzoglou/chain-view/config"
+	"github.com/pampatzoglou/chain-view/internal/logging"
+	"github.com/pampatzoglou/chain-view/internal/metrics"
+)
+
+// unreachableURL is an endpoint URL nothing listens on.
+const unreachableURL = "http://127.0.0.1:1"
+
+// fakeClock is a manually advanced clock for circuit breaker tests.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestPool creates a pool for chain with metrics on a private registry.
+func newTestPool(t *testing.T, chain config.ChainConfig) *EndpointPool {
+	t.Helper()
+	logger := logging.NewLogger("error")
+	pool, err := NewEndpointPool(chain, logger, metrics.NewMetricsManager(logger, prometheus.NewRegistry()))
+	if err != nil {
+		t.Fatalf("NewEndpointPool: %v", err)
+	}
+	return pool
+}
+
+// testPoolOption changes the chain configuration of a pool created by newTestServerPool.
+type testPoolOption func(chain *config.ChainConfig)
+
+// newTestServerPool creates a pool of chain 1 with a single endpoint, provider, served by
+// handler. A nil handler points provider at unreachableURL.
+func newTestServerPool(t *testing.T, handler http.Handler, opts ...testPoolOption) *EndpointPool {
+	t.Helper()
+	url := unreachableURL
+	if handler != nil {
+		url = newTestServer(t, handler)
+	}
+	chain := config.ChainConfig{
+		Network:   "test",
+		ChainID:   1,
+		Endpoints: []config.EndpointConfig{testEndpoint("provider", url)},
+	}
+	for _, opt := range opts {
+		opt(&chain)
+	}
+	return newTestPool(t, chain)
+}
+
+// newTestServer starts an HTTP server for the duration of the test and returns its URL.
+func newTestServer(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// testEndpoint returns the configuration of an endpoint that is not rate limited in practice.
+func testEndpoint(name, url string) config.EndpointConfig {
+	return config.EndpointConfig{
+		Name:      name,
+		URL:       url,
+		Timeout:   config.Duration{Duration: time.Second},
+		RateLimit: 1000,
+	}
+}
+
+// withEndpoint adds an endpoint to the pool.
+func withEndpoint(name, url string) testPoolOption {
+	return func(chain *config.ChainConfig) {
+		chain.Endpoints = append(chain.Endpoints, testEndpoint(name, url))
+	}
+}
+
+// withProvider changes the configuration of the provider endpoint.
+func withProvider(change func(e *config.EndpointConfig)) testPoolOption {
+	return func(chain *config.ChainConfig) {
+		change(&chain.Endpoints[0])
+	}
+}
+
+// rpcResult returns a handler that answers every request with result.
+func rpcResult(result string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+	})
+}
+
+// withTrialBreaker opens the breaker after one failure and allows one trial request.
+func withTrialBreaker(chain *config.ChainConfig) {
+	chain.CircuitBreaker = config.CircuitBreakerConfig{FailureThreshold: 1, HalfOpenMaxRequests: 1}
+}
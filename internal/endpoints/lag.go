@@ -0,0 +1,175 @@
+package endpoints
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// headTracker keeps the latest block reported by every endpoint of a pool and
+// marks endpoints that fall too far behind the best-known head as degraded.
+type headTracker struct {
+	mu       sync.Mutex
+	heads    map[string]uint64
+	degraded map[string]bool
+}
+
+// newHeadTracker creates an empty headTracker.
+func newHeadTracker() *headTracker {
+	return &headTracker{
+		heads:    make(map[string]uint64),
+		degraded: make(map[string]bool),
+	}
+}
+
+// lagChange describes an endpoint whose degraded state changed.
+type lagChange struct {
+	endpoint string
+	lag      uint64
+	degraded bool
+}
+
+// update records the head of an endpoint and returns the best head, the lag of every
+// endpoint and the endpoints whose degraded state changed. A maxLag of zero disables degrading.
+func (t *headTracker) update(endpoint string, height, maxLag uint64) (uint64, map[string]uint64, []lagChange) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.heads[endpoint] = height
+	return t.evaluate(maxLag)
+}
+
+// remove forgets the head of an endpoint and returns the best head, lags and degraded
+// changes of the remaining endpoints, as update does.
+func (t *headTracker) remove(endpoint string, maxLag uint64) (uint64, map[string]uint64, []lagChange) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.heads, endpoint)
+	delete(t.degraded, endpoint)
+	return t.evaluate(maxLag)
+}
+
+// evaluate computes the best head and the lag of every endpoint, and updates their
+// degraded state. Callers hold t.mu.
+func (t *headTracker) evaluate(maxLag uint64) (uint64, map[string]uint64, []lagChange) {
+	var best uint64
+	for _, h := range t.heads {
+		if h > best {
+			best = h
+		}
+	}
+
+	lags := make(map[string]uint64, len(t.heads))
+	var changes []lagChange
+	for name, h := range t.heads {
+		lag := best - h
+		lags[name] = lag
+		degraded := maxLag > 0 && lag > maxLag
+		if degraded != t.degraded[name] {
+			t.degraded[name] = degraded
+			changes = append(changes, lagChange{endpoint: name, lag: lag, degraded: degraded})
+		}
+	}
+	return best, lags, changes
+}
+
+// isDegraded reports whether the endpoint is behind the best head by more than the allowed lag.
+func (t *headTracker) isDegraded(endpoint string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.degraded[endpoint]
+}
+
+// head returns the latest block reported by the endpoint and whether it reported one.
+func (t *headTracker) head(endpoint string) (uint64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.heads[endpoint]
+	return h, ok
+}
+
+// snapshot returns a copy of the latest block of every endpoint and the best head.
+func (t *headTracker) snapshot() (map[string]uint64, uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	heads := make(map[string]uint64, len(t.heads))
+	var best uint64
+	for name, h := range t.heads {
+		heads[name] = h
+		if h > best {
+			best = h
+		}
+	}
+	return heads, best
+}
+
+// retain forgets every endpoint that is not in names.
+func (t *headTracker) retain(names map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for name := range t.heads {
+		if !names[name] {
+			delete(t.heads, name)
+			delete(t.degraded, name)
+		}
+	}
+}
+
+// recordHead stores the latest block of an endpoint, exports its height and the lag of
+// every endpoint, and logs endpoints that become degraded or recover.
+func (ep *EndpointPool) recordHead(endpoint string, height uint64) {
+	ep.metrics.CurrentBlockHeight.WithLabelValues(ep.Network, endpoint).Set(float64(height))
+
+	ep.mu.RLock()
+	maxLag := ep.MaxBlockLag
+	ep.mu.RUnlock()
+
+	best, lags, changes := ep.heads.update(endpoint, height, maxLag)
+	ep.reportLag(best, lags, changes, maxLag)
+}
+
+// forgetHead drops the head of an endpoint whose blocks must not count, such as one serving
+// another chain, and re-evaluates the lag of the others against the remaining best head.
+func (ep *EndpointPool) forgetHead(endpoint string) {
+	ep.metrics.CurrentBlockHeight.DeleteLabelValues(ep.Network, endpoint)
+	ep.metrics.BlockLag.DeleteLabelValues(ep.Network, endpoint)
+
+	ep.mu.RLock()
+	maxLag := ep.MaxBlockLag
+	ep.mu.RUnlock()
+
+	best, lags, changes := ep.heads.remove(endpoint, maxLag)
+	ep.reportLag(best, lags, changes, maxLag)
+}
+
+// reportLag exports the lag of every endpoint and logs endpoints that became degraded or recovered.
+func (ep *EndpointPool) reportLag(best uint64, lags map[string]uint64, changes []lagChange, maxLag uint64) {
+	for name, lag := range lags {
+		ep.metrics.BlockLag.WithLabelValues(ep.Network, name).Set(float64(lag))
+	}
+
+	for _, c := range changes {
+		fields := logrus.Fields{
+			"network":       ep.Network,
+			"endpoint":      c.endpoint,
+			"lag":           c.lag,
+			"best_head":     best,
+			"max_block_lag": maxLag,
+		}
+		if c.degraded {
+			ep.logger.WithFields(fields).Warn("Endpoint is lagging behind the chain head, removing from selection")
+		} else {
+			ep.logger.WithFields(fields).Info("Endpoint caught up with the chain head")
+		}
+	}
+}
+
+// BestHead returns the highest block reported by any endpoint of the pool, or 0 before the
+// first probe.
+func (ep *EndpointPool) BestHead() uint64 {
+	_, best := ep.heads.snapshot()
+	return best
+}
@@ -0,0 +1,105 @@
+package endpoints
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+func TestHeadTrackerLag(t *testing.T) {
+	type head struct {
+		endpoint string
+		height   uint64
+	}
+	tests := []struct {
+		name         string
+		maxLag       uint64
+		heads        []head
+		remove       string
+		wantLags     map[string]uint64
+		wantDegraded []string
+	}{
+		{
+			name:     "in sync",
+			maxLag:   5,
+			heads:    []head{{"a", 100}, {"b", 100}},
+			wantLags: map[string]uint64{"a": 0, "b": 0},
+		},
+		{
+			name:     "lag at the limit",
+			maxLag:   5,
+			heads:    []head{{"a", 100}, {"b", 95}},
+			wantLags: map[string]uint64{"a": 0, "b": 5},
+		},
+		{
+			name:         "lag above the limit",
+			maxLag:       5,
+			heads:        []head{{"a", 100}, {"b", 94}},
+			wantLags:     map[string]uint64{"a": 0, "b": 6},
+			wantDegraded: []string{"b"},
+		},
+		{
+			name:     "recovered",
+			maxLag:   5,
+			heads:    []head{{"a", 100}, {"b", 90}, {"b", 101}},
+			wantLags: map[string]uint64{"a": 1, "b": 0},
+		},
+		{
+			name:     "degrading disabled",
+			heads:    []head{{"a", 1000}, {"b", 1}},
+			wantLags: map[string]uint64{"a": 0, "b": 999},
+		},
+		{
+			name:     "best head removed",
+			maxLag:   5,
+			heads:    []head{{"a", 100}, {"b", 90}, {"c", 91}},
+			remove:   "a",
+			wantLags: map[string]uint64{"b": 1, "c": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newHeadTracker()
+			var lags map[string]uint64
+			for _, h := range tt.heads {
+				_, lags, _ = tracker.update(h.endpoint, h.height, tt.maxLag)
+			}
+			if tt.remove != "" {
+				_, lags, _ = tracker.remove(tt.remove, tt.maxLag)
+			}
+			if !reflect.DeepEqual(lags, tt.wantLags) {
+				t.Fatalf("lags = %v, want %v", lags, tt.wantLags)
+			}
+			var degraded []string
+			for _, name := range []string{"a", "b", "c"} {
+				if tracker.isDegraded(name) {
+					degraded = append(degraded, name)
+				}
+			}
+			if !reflect.DeepEqual(degraded, tt.wantDegraded) {
+				t.Fatalf("degraded = %v, want %v", degraded, tt.wantDegraded)
+			}
+		})
+	}
+}
+
+func TestDegradedEndpointsAreNotAvailable(t *testing.T) {
+	pool := newTestPool(t, reloadTestChain(config.CircuitBreakerConfig{}, "a", "b"))
+
+	pool.recordHead("a", 100)
+	pool.recordHead("b", 80)
+	if lag := testutil.ToFloat64(pool.metrics.BlockLag.WithLabelValues(pool.Network, "b")); lag != 20 {
+		t.Fatalf("lag of b = %v, want 20", lag)
+	}
+	if available := pool.availableEndpoints(); len(available) != 1 || available[0].Name != "a" {
+		t.Fatalf("available = %v, want only a", available)
+	}
+
+	pool.recordHead("b", 100)
+	if available := pool.availableEndpoints(); len(available) != 2 {
+		t.Fatalf("%d endpoints available after b caught up, want 2", len(available))
+	}
+}
@@ -0,0 +1,216 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/pampatzoglou/chain-view/config"
+	"github.com/pampatzoglou/chain-view/internal/database"
+	"github.com/pampatzoglou/chain-view/internal/logging"
+	"github.com/pampatzoglou/chain-view/internal/metrics"
+)
+
+// PoolSet runs the endpoint pools of every configured chain and applies configuration
+// reloads. Pools of added chains are started, pools of removed chains are stopped once
+// their workers have finished, and pools of remaining chains are reloaded in place.
+type PoolSet struct {
+	mu       sync.Mutex
+	ctx      context.Context
+	pools    map[string]*runningPool // by network name
+	order    []string
+	wg       sync.WaitGroup
+	logger   *logging.Logger
+	metrics  *metrics.MetricsManager
+	checks   *database.CheckWriter
+	tasks    []func(context.Context, *EndpointPool)
+	onChange []func([]*EndpointPool)
+}
+
+// runningPool is a started pool together with the means to stop it.
+type runningPool struct {
+	pool   *EndpointPool
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// NewPoolSet creates an empty PoolSet. Pools are started with ctx and stop when it is canceled.
+// The probes of every pool are recorded to checks; a nil checks keeps no probe history.
+func NewPoolSet(ctx context.Context, logger *logging.Logger, mm *metrics.MetricsManager, checks *database.CheckWriter) *PoolSet {
+	return &PoolSet{
+		ctx:     ctx,
+		pools:   make(map[string]*runningPool),
+		logger:  logger,
+		metrics: mm,
+		checks:  checks,
+	}
+}
+
+// OnChange registers fn to be called with the current pools after every Apply.
+func (s *PoolSet) OnChange(fn func([]*EndpointPool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = append(s.onChange, fn)
+}
+
+// AddTask registers fn to run alongside the background work of every pool started from now
+// on, so it should be called before the first Apply. fn must return once ctx is canceled,
+// which happens when the pool is stopped.
+func (s *PoolSet) AddTask(fn func(ctx context.Context, pool *EndpointPool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = append(s.tasks, fn)
+}
+
+// Pools returns the running pools in configuration order.
+func (s *PoolSet) Pools() []*EndpointPool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current()
+}
+
+// current returns the running pools in configuration order. Callers hold s.mu.
+func (s *PoolSet) current() []*EndpointPool {
+	pools := make([]*EndpointPool, 0, len(s.order))
+	for _, network := range s.order {
+		pools = append(pools, s.pools[network].pool)
+	}
+	return pools
+}
+
+// Apply brings the running pools in line with chains. Each chain is applied atomically:
+// a chain whose configuration is invalid keeps its running pool, if any, and is reported
+// in the returned errors while the other chains are still applied.
+func (s *PoolSet) Apply(chains []config.ChainConfig, global config.GlobalSettings) []error {
+	s.mu.Lock()
+
+	var errors []error
+	wanted := make(map[string]bool, len(chains))
+	var order []string
+	for _, chain := range chains {
+		if wanted[chain.Network] {
+			errors = append(errors, fmt.Errorf("chain %s is configured more than once", chain.Network))
+			continue
+		}
+		wanted[chain.Network] = true
+		chain = applyGlobalSettings(chain, global)
+
+		running, ok := s.pools[chain.Network]
+		if ok && running.pool.ChainID == chain.ChainID {
+			if err := running.pool.ReloadConfig(chain); err != nil {
+				errors = append(errors, fmt.Errorf("failed to reload endpoint pool for chain %s (ID: %d): %w", chain.Network, chain.ChainID, err))
+			}
+			order = append(order, chain.Network)
+			continue
+		}
+
+		pool, err := NewEndpointPool(chain, s.logger, s.metrics)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("failed to create endpoint pool for chain %s (ID: %d): %w", chain.Network, chain.ChainID, err))
+			if ok {
+				order = append(order, chain.Network) // Keep the pool running with its old chain ID
+			}
+			continue
+		}
+		if ok {
+			s.stop(chain.Network, running)
+		}
+		s.pools[chain.Network] = s.start(pool, global.MaxWorkers)
+		order = append(order, chain.Network)
+	}
+
+	for network, running := range s.pools {
+		if !wanted[network] {
+			s.stop(network, running)
+			delete(s.pools, network)
+		}
+	}
+	s.order = order
+
+	pools := s.current()
+	callbacks := s.onChange
+	s.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(pools)
+	}
+	return errors
+}
+
+// start launches the background work of a pool.
+func (s *PoolSet) start(pool *EndpointPool, numWorkers int) *runningPool {
+	ctx, cancel := context.WithCancel(s.ctx)
+	running := &runningPool{pool: pool, cancel: cancel}
+	pool.checks = s.checks
+
+	tasks := []func(){
+		func() { pool.ProcessEndpoints(ctx, numWorkers, s.logger) },
+		func() { pool.LogCircuitBreakerMetrics(ctx, s.logger) },
+		func() { pool.TrackFinality(ctx) },
+		func() { pool.VerifyChainIDs(ctx) },
+		func() { pool.LogChainConfig(ctx, s.logger) },
+	}
+	for _, fn := range s.tasks {
+		tasks = append(tasks, func() { fn(ctx, pool) })
+	}
+	running.done.Add(len(tasks))
+	s.wg.Add(len(tasks))
+	for _, task := range tasks {
+		go func(task func()) {
+			defer s.wg.Done()
+			defer running.done.Done()
+			task()
+		}(task)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"network":  pool.Network,
+		"chain_id": pool.ChainID,
+	}).Info("Started endpoint pool")
+	return running
+}
+
+// stop cancels a pool and waits in the background for its in-flight jobs, so a reload is
+// not held up by slow requests. When no pool runs for the chain anymore its endpoint info,
+// chain ID and balance series are dropped. When the pool was replaced, as after a chain ID
+// change, the series of its endpoints are dropped and the replacing pool exports its own again.
+func (s *PoolSet) stop(network string, running *runningPool) {
+	running.cancel()
+	go func() {
+		running.done.Wait()
+
+		s.mu.Lock()
+		replacement, replaced := s.pools[network]
+		if replaced {
+			for _, e := range running.pool.endpoints() {
+				running.pool.deleteEndpointMetrics(e.Name)
+			}
+			replacement.pool.reportEndpointState()
+		} else {
+			s.metrics.EndpointInfo.DeletePartialMatch(prometheus.Labels{"chain": network})
+			s.metrics.ChainIDMismatch.DeletePartialMatch(prometheus.Labels{"chain": network})
+			s.metrics.ReportedChainID.DeletePartialMatch(prometheus.Labels{"chain": network})
+			s.metrics.EndpointAdminState.DeletePartialMatch(prometheus.Labels{"chain": network})
+			s.metrics.AddressBalance.DeletePartialMatch(prometheus.Labels{"chain": network})
+			for _, gauge := range []*prometheus.GaugeVec{
+				s.metrics.CurrentBlockHeight, s.metrics.BlockLag, s.metrics.CircuitBreakerState,
+				s.metrics.FinalizedBlocks, s.metrics.SafeBlocks, s.metrics.FinalityGap, s.metrics.FinalityStalled,
+			} {
+				gauge.DeletePartialMatch(prometheus.Labels{"chain": network})
+			}
+		}
+		s.mu.Unlock()
+		s.logger.WithFields(logrus.Fields{
+			"network":  network,
+			"chain_id": running.pool.ChainID,
+		}).Info("Stopped endpoint pool")
+	}()
+}
+
+// Wait blocks until the background work of every pool started by the set has returned.
+func (s *PoolSet) Wait() {
+	s.wg.Wait()
+}
@@ -0,0 +1,67 @@
+package endpoints
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/pampatzoglou/chain-view/config"
+	"github.com/pampatzoglou/chain-view/internal/logging"
+	"github.com/pampatzoglou/chain-view/internal/metrics"
+)
+
+func TestApplyDropsSeriesOfPoolReplacedForChainIDChange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	logger := logging.NewLogger("error")
+	mm := metrics.NewMetricsManager(logger, prometheus.NewRegistry())
+	set := NewPoolSet(ctx, logger, mm, nil)
+	defer set.Wait()
+	defer cancel()
+
+	global := config.GlobalSettings{MaxWorkers: 1}
+	chain := reloadTestChain(config.CircuitBreakerConfig{}, "old")
+	if errs := set.Apply([]config.ChainConfig{chain}, global); len(errs) > 0 {
+		t.Fatalf("Apply: %v", errs)
+	}
+	old := set.Pools()[0]
+	if _, err := old.SetEndpointState("old", AdminDisabled, "test"); err != nil {
+		t.Fatalf("SetEndpointState: %v", err)
+	}
+	old.reportCircuitBreakerState(old.endpoints()[0])
+	mm.QuotaRemaining.WithLabelValues(old.Network, "old", QuotaDaily).Set(10)
+	mm.UpdateMetrics(old.Network, "old", 90, 100)
+
+	chain = reloadTestChain(config.CircuitBreakerConfig{}, "new")
+	chain.ChainID = 2
+	if errs := set.Apply([]config.ChainConfig{chain}, global); len(errs) > 0 {
+		t.Fatalf("Apply: %v", errs)
+	}
+
+	// The old pool is stopped in the background; its admin state is the last series it had
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.CollectAndCount(mm.EndpointAdminState) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("admin state of the replaced pool kept")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for name, series := range map[string]struct {
+		count, want int
+	}{
+		"endpoint_info":        {testutil.CollectAndCount(mm.EndpointInfo), 1},
+		"circuit_state":        {testutil.CollectAndCount(mm.CircuitBreakerState), 1},
+		"quota_remaining":      {testutil.CollectAndCount(mm.QuotaRemaining), 0},
+		"current_block_height": {testutil.CollectAndCount(mm.CurrentBlockHeight), 0},
+		"block_lag":            {testutil.CollectAndCount(mm.BlockLag), 0},
+	} {
+		if series.count != series.want {
+			t.Errorf("%s has %d series, want %d", name, series.count, series.want)
+		}
+	}
+	if info := testutil.ToFloat64(mm.EndpointInfo.WithLabelValues(chain.Network, "new", "127.0.0.1", "http")); info != 1 {
+		t.Fatal("endpoint info of the replacing pool is not exported")
+	}
+}
@@ -0,0 +1,33 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+func TestNextProbeEndpointSkipsEndpointsOutOfRotation(t *testing.T) {
+	pool := newTestPool(t, reloadTestChain(config.CircuitBreakerConfig{}, "a", "b", "c"))
+	if _, err := pool.SetEndpointState("b", AdminDisabled, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		endpoint, ok := pool.nextProbeEndpoint()
+		if !ok {
+			t.Fatal("no endpoint to probe")
+		}
+		if endpoint.Name == "b" {
+			t.Fatal("disabled endpoint was probed")
+		}
+	}
+
+	for _, name := range []string{"a", "c"} {
+		if _, err := pool.SetEndpointState(name, AdminDisabled, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if endpoint, ok := pool.nextProbeEndpoint(); ok {
+		t.Fatalf("probing %s with every endpoint disabled", endpoint.Name)
+	}
+}
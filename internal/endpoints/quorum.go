@@ -0,0 +1,219 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+// ErrNoQuorum is returned when not enough endpoints agree on a quorum read.
+var ErrNoQuorum = errors.New("quorum not reached")
+
+// QuorumResult is the outcome of a quorum read.
+type QuorumResult struct {
+	Body     []byte   // response of the first endpoint in the majority
+	Agreeing []string // endpoints that returned the majority answer
+	Outvoted []string // endpoints that answered differently
+	Failed   []string // endpoints that could not be asked or did not answer
+	Required int      // number of agreeing endpoints needed
+}
+
+// quorumVote is the answer of a single endpoint to a quorum read.
+type quorumVote struct {
+	endpoint string
+	body     []byte
+	key      string
+	err      error
+}
+
+// quorumSettings returns the number of endpoints to ask and the number that must agree.
+func (ep *EndpointPool) quorumSettings() (int, int) {
+	ep.mu.RLock()
+	size, threshold := ep.QuorumSize, ep.QuorumThreshold
+	ep.mu.RUnlock()
+
+	if size <= 0 {
+		size = config.DefaultQuorumSize
+	}
+	if threshold <= 0 {
+		threshold = size/2 + 1
+	}
+	return size, threshold
+}
+
+// QuorumCall sends the same JSON-RPC request to several endpoints and returns the answer
+// the majority agrees on. Results are compared after normalization, so differences in
+// id, key order or hex case do not count as disagreement. Endpoints in the minority are
+// counted in the quorum mismatch metric.
+func (ep *EndpointPool) QuorumCall(ctx context.Context, payload []byte) (*QuorumResult, error) {
+	size, threshold := ep.quorumSettings()
+	result := &QuorumResult{Required: threshold}
+
+	var selected []Endpoint
+	tried := make(map[string]bool, size)
+	for len(selected) < size {
+		endpoint, ok := ep.nextUntried(tried)
+		if !ok {
+			break
+		}
+		tried[endpoint.Name] = true
+		if !ep.acquire(endpoint) {
+			result.Failed = append(result.Failed, endpoint.Name)
+			continue
+		}
+		selected = append(selected, endpoint)
+	}
+	if len(selected) < threshold {
+		for _, endpoint := range selected {
+			endpoint.circuitBreaker.Release()
+		}
+		return result, fmt.Errorf("%w for %s: only %d of %d required endpoints available", ErrNoQuorum, ep.Network, len(selected), threshold)
+	}
+
+	votes := make([]quorumVote, len(selected))
+	var wg sync.WaitGroup
+	for i, endpoint := range selected {
+		wg.Add(1)
+		go func(i int, endpoint Endpoint) {
+			defer wg.Done()
+			votes[i] = ep.quorumVote(ctx, endpoint, payload)
+		}(i, endpoint)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+
+	// Tally the normalized answers and pick the largest group
+	counts := make(map[string]int)
+	var winner string
+	for _, v := range votes {
+		if v.err != nil {
+			result.Failed = append(result.Failed, v.endpoint)
+			continue
+		}
+		counts[v.key]++
+		if counts[v.key] > counts[winner] {
+			winner = v.key
+		}
+	}
+	for key, count := range counts {
+		if key != winner && count == counts[winner] {
+			return result, fmt.Errorf("%w for %s: answers are tied at %d endpoints each", ErrNoQuorum, ep.Network, count)
+		}
+	}
+
+	for _, v := range votes {
+		if v.err != nil {
+			continue
+		}
+		if v.key == winner {
+			if result.Body == nil {
+				result.Body = v.body
+			}
+			result.Agreeing = append(result.Agreeing, v.endpoint)
+		} else {
+			result.Outvoted = append(result.Outvoted, v.endpoint)
+		}
+	}
+
+	if len(result.Agreeing) < threshold {
+		result.Body = nil
+		return result, fmt.Errorf("%w for %s: %d of %d required endpoints agree", ErrNoQuorum, ep.Network, len(result.Agreeing), threshold)
+	}
+
+	for _, name := range result.Outvoted {
+		ep.metrics.QuorumMismatches.WithLabelValues(ep.Network, name).Inc()
+	}
+	if len(result.Outvoted) > 0 {
+		ep.logger.WithFields(logrus.Fields{
+			"network":  ep.Network,
+			"agreeing": result.Agreeing,
+			"outvoted": result.Outvoted,
+		}).Warn("Endpoints outvoted in quorum read")
+	}
+	return result, nil
+}
+
+// quorumVote sends the payload to one endpoint and normalizes its answer.
+func (ep *EndpointPool) quorumVote(ctx context.Context, endpoint Endpoint, payload []byte) quorumVote {
+	vote := quorumVote{endpoint: endpoint.Name}
+
+	start := time.Now()
+	status, body, err := ep.postRPC(ctx, endpoint, payload)
+	if err == nil && status != http.StatusOK {
+		err = &HTTPStatusError{StatusCode: status}
+	}
+	if err == nil {
+		vote.key, err = normalizeResponse(body)
+	}
+	if !ep.abandoned(ctx, endpoint, err) {
+		ep.recordOutcome(endpoint, time.Since(start), err)
+	}
+
+	vote.body, vote.err = body, err
+	return vote
+}
+
+// normalizeResponse reduces a JSON-RPC response to a comparable key. The id is ignored,
+// objects are re-encoded with sorted keys and hex strings are compared by value, so 0x01,
+// 0x1 and 0X1 agree.
+// JSON-RPC errors are compared by code only, as providers word their messages differently.
+func normalizeResponse(body []byte) (string, error) {
+	var resp rpcResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("invalid JSON-RPC response: %w", err)
+	}
+	if resp.Error != nil {
+		return fmt.Sprintf("error:%d", resp.Error.Code), nil
+	}
+
+	var value interface{}
+	if len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, &value); err != nil {
+			return "", fmt.Errorf("invalid JSON-RPC result: %w", err)
+		}
+	}
+	normalized, err := json.Marshal(normalizeValue(value))
+	if err != nil {
+		return "", err
+	}
+	return "result:" + string(normalized), nil
+}
+
+// normalizeValue rewrites hex strings throughout a decoded JSON value in canonical form,
+// lower case without leading zeros.
+func normalizeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		if strings.HasPrefix(t, "0x") || strings.HasPrefix(t, "0X") {
+			if n, ok := new(big.Int).SetString(t[2:], 16); ok {
+				return "0x" + n.Text(16)
+			}
+			return strings.ToLower(t)
+		}
+		return t
+	case []interface{}:
+		for i := range t {
+			t[i] = normalizeValue(t[i])
+		}
+		return t
+	case map[string]interface{}:
+		for k := range t {
+			t[k] = normalizeValue(t[k])
+		}
+		return t
+	default:
+		return v
+	}
+}
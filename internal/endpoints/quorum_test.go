@@ -0,0 +1,80 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+// quorumTestPool creates a pool with one endpoint per answer, named a, b, c and so on.
+// An empty answer leaves the endpoint unreachable.
+func quorumTestPool(t *testing.T, quorum config.QuorumConfig, answers ...string) *EndpointPool {
+	t.Helper()
+	chain := config.ChainConfig{Network: "test", ChainID: 1, Quorum: quorum}
+	for i, answer := range answers {
+		url := unreachableURL
+		if answer != "" {
+			url = newTestServer(t, rpcResult(answer))
+		}
+		chain.Endpoints = append(chain.Endpoints, testEndpoint(string(rune('a'+i)), url))
+	}
+	return newTestPool(t, chain)
+}
+
+func TestQuorumCall(t *testing.T) {
+	tests := []struct {
+		name     string
+		quorum   config.QuorumConfig
+		answers  []string
+		agreeing int
+		outvoted int
+		failed   int
+		wantErr  bool
+	}{
+		{name: "all agree", answers: []string{`"0x10"`, `"0x10"`, `"0x10"`}, agreeing: 3},
+		{name: "majority", answers: []string{`"0x10"`, `"0x10"`, `"0x11"`}, agreeing: 2, outvoted: 1},
+		{name: "hex by value", answers: []string{`"0x01"`, `"0x1"`, `"0X1"`}, agreeing: 3},
+		{name: "objects by value", answers: []string{`{"number":"0xA","hash":"0xab"}`, `{"hash":"0xAB","number":"0x0a"}`, `"0x1"`}, agreeing: 2, outvoted: 1},
+		{name: "one endpoint down", answers: []string{`"0x10"`, `"0x10"`, ""}, agreeing: 2, failed: 1},
+		{name: "no majority", answers: []string{`"0x10"`, `"0x11"`, `"0x12"`}, wantErr: true},
+		// Validate rejects thresholds below a majority, the pool must still not pick a side
+		{name: "tie", quorum: config.QuorumConfig{Size: 4, Threshold: 2}, answers: []string{`"0x10"`, `"0x10"`, `"0x11"`, `"0x11"`}, wantErr: true},
+		{name: "too few endpoints", answers: []string{`"0x10"`}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := quorumTestPool(t, tt.quorum, tt.answers...)
+
+			result, err := pool.QuorumCall(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`))
+			if tt.wantErr {
+				if !errors.Is(err, ErrNoQuorum) {
+					t.Fatalf("err = %v, want ErrNoQuorum", err)
+				}
+				if result.Body != nil {
+					t.Fatal("body returned without a quorum")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("QuorumCall: %v", err)
+			}
+			if result.Body == nil {
+				t.Fatal("no body returned")
+			}
+			got := fmt.Sprintf("%d/%d/%d", len(result.Agreeing), len(result.Outvoted), len(result.Failed))
+			if want := fmt.Sprintf("%d/%d/%d", tt.agreeing, tt.outvoted, tt.failed); got != want {
+				t.Fatalf("agreeing/outvoted/failed = %s, want %s", got, want)
+			}
+			for _, name := range result.Outvoted {
+				if n := testutil.ToFloat64(pool.metrics.QuorumMismatches.WithLabelValues(pool.Network, name)); n != 1 {
+					t.Errorf("mismatches of %s = %v, want 1", name, n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package endpoints
+
+import (
+	"sync"
+	"time"
+)
+
+// Quota periods used as metric labels.
+const (
+	QuotaDaily   = "daily"
+	QuotaMonthly = "monthly"
+)
+
+// quotaWarningRatio is the share of a quota left when a warning is logged.
+const quotaWarningRatio = 0.1
+
+// Quota tracks request usage of an endpoint against optional daily and monthly limits.
+// Periods follow UTC calendar days and months. A limit of zero means unlimited.
+type Quota struct {
+	dailyLimit    int64
+	monthlyLimit  int64
+	dailyUsed     int64
+	monthlyUsed   int64
+	dailyWarned   bool
+	monthlyWarned bool
+	day           time.Time
+	month         time.Time
+	now           func() time.Time
+	mu            sync.Mutex
+}
+
+// QuotaUsage is a snapshot of the remaining requests per period.
+// Remaining is -1 for periods without a limit.
+type QuotaUsage struct {
+	DailyRemaining   int64 `json:"daily_remaining"`
+	MonthlyRemaining int64 `json:"monthly_remaining"`
+}
+
+// NewQuota creates a Quota with the given daily and monthly limits.
+func NewQuota(dailyLimit, monthlyLimit int64) *Quota {
+	q := &Quota{now: time.Now}
+	q.SetLimits(dailyLimit, monthlyLimit)
+	return q
+}
+
+// SetLimits changes the limits while keeping the usage of the current periods.
+func (q *Quota) SetLimits(dailyLimit, monthlyLimit int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dailyLimit = dailyLimit
+	q.monthlyLimit = monthlyLimit
+}
+
+// Consume uses one request of the quota and reports whether it was available.
+func (q *Quota) Consume() bool {
+	return q.ConsumeN(1)
+}
+
+// ConsumeN uses n requests of the quota, such as the elements of a batch, and reports
+// whether all of them were available. Nothing is used when they were not.
+func (q *Quota) ConsumeN(n int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollover()
+	if (q.dailyLimit > 0 && q.dailyUsed+n > q.dailyLimit) ||
+		(q.monthlyLimit > 0 && q.monthlyUsed+n > q.monthlyLimit) {
+		return false
+	}
+	q.dailyUsed += n
+	q.monthlyUsed += n
+	return true
+}
+
+// Exhausted reports whether any period has no requests left.
+func (q *Quota) Exhausted() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollover()
+	return q.exhausted()
+}
+
+// Usage returns the remaining requests of each period.
+func (q *Quota) Usage() QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollover()
+	return QuotaUsage{
+		DailyRemaining:   remaining(q.dailyLimit, q.dailyUsed),
+		MonthlyRemaining: remaining(q.monthlyLimit, q.monthlyUsed),
+	}
+}
+
+// NeedsWarning reports once per period that a limited period has less than
+// quotaWarningRatio of its requests left.
+func (q *Quota) NeedsWarning() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	warn := false
+	if !q.dailyWarned && low(q.dailyLimit, q.dailyUsed) {
+		q.dailyWarned, warn = true, true
+	}
+	if !q.monthlyWarned && low(q.monthlyLimit, q.monthlyUsed) {
+		q.monthlyWarned, warn = true, true
+	}
+	return warn
+}
+
+// rollover resets the usage of periods that have ended. Callers hold q.mu.
+func (q *Quota) rollover() {
+	now := q.now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if !day.Equal(q.day) {
+		q.day = day
+		q.dailyUsed = 0
+		q.dailyWarned = false
+	}
+	if !month.Equal(q.month) {
+		q.month = month
+		q.monthlyUsed = 0
+		q.monthlyWarned = false
+	}
+}
+
+// exhausted reports whether any period is used up. Callers hold q.mu.
+func (q *Quota) exhausted() bool {
+	return (q.dailyLimit > 0 && q.dailyUsed >= q.dailyLimit) ||
+		(q.monthlyLimit > 0 && q.monthlyUsed >= q.monthlyLimit)
+}
+
+func remaining(limit, used int64) int64 {
+	if limit <= 0 {
+		return -1
+	}
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
+
+func low(limit, used int64) bool {
+	return limit > 0 && float64(limit-used) < float64(limit)*quotaWarningRatio
+}
@@ -0,0 +1,163 @@
+package endpoints
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+func TestQuotaConsume(t *testing.T) {
+	tests := []struct {
+		name          string
+		daily         int64
+		monthly       int64
+		consume       []int64 // requests per call
+		want          []bool
+		wantRemaining QuotaUsage
+	}{
+		{
+			name:          "unlimited",
+			consume:       []int64{1, 1000},
+			want:          []bool{true, true},
+			wantRemaining: QuotaUsage{DailyRemaining: -1, MonthlyRemaining: -1},
+		},
+		{
+			name:          "daily limit",
+			daily:         3,
+			consume:       []int64{1, 1, 1, 1},
+			want:          []bool{true, true, true, false},
+			wantRemaining: QuotaUsage{DailyRemaining: 0, MonthlyRemaining: -1},
+		},
+		{
+			name:          "monthly limit",
+			monthly:       2,
+			consume:       []int64{1, 1, 1},
+			want:          []bool{true, true, false},
+			wantRemaining: QuotaUsage{DailyRemaining: -1, MonthlyRemaining: 0},
+		},
+		{
+			name:          "batch taken whole or not at all",
+			daily:         10,
+			consume:       []int64{8, 3, 2},
+			want:          []bool{true, false, true},
+			wantRemaining: QuotaUsage{DailyRemaining: 0, MonthlyRemaining: -1},
+		},
+		{
+			name:          "tighter of both limits",
+			daily:         10,
+			monthly:       5,
+			consume:       []int64{4, 2},
+			want:          []bool{true, false},
+			wantRemaining: QuotaUsage{DailyRemaining: 6, MonthlyRemaining: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQuota(tt.daily, tt.monthly)
+			for i, n := range tt.consume {
+				if got := q.ConsumeN(n); got != tt.want[i] {
+					t.Fatalf("ConsumeN(%d) #%d = %v, want %v", n, i, got, tt.want[i])
+				}
+			}
+			if usage := q.Usage(); usage != tt.wantRemaining {
+				t.Fatalf("usage = %+v, want %+v", usage, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestQuotaRollover(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC)}
+	q := NewQuota(2, 3)
+	q.now = clock.now
+
+	q.ConsumeN(2)
+	if !q.Exhausted() {
+		t.Fatal("daily quota not exhausted")
+	}
+
+	// A new day and a new month
+	clock.advance(2 * time.Hour)
+	if q.Exhausted() {
+		t.Fatal("quota still exhausted in the next period")
+	}
+	q.ConsumeN(2)
+	clock.advance(24 * time.Hour)
+	if usage := q.Usage(); usage != (QuotaUsage{DailyRemaining: 2, MonthlyRemaining: 1}) {
+		t.Fatalf("usage = %+v, want the daily quota reset and the monthly one kept", usage)
+	}
+}
+
+func TestQuotaWarnsOncePerPeriod(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)}
+	q := NewQuota(100, 0)
+	q.now = clock.now
+
+	q.ConsumeN(90)
+	if q.NeedsWarning() {
+		t.Fatal("warning with 10% left")
+	}
+	q.Consume()
+	if !q.NeedsWarning() {
+		t.Fatal("no warning with less than 10% left")
+	}
+	q.Consume()
+	if q.NeedsWarning() {
+		t.Fatal("warning repeated within the period")
+	}
+
+	clock.advance(24 * time.Hour)
+	q.ConsumeN(91)
+	if !q.NeedsWarning() {
+		t.Fatal("no warning in the next period")
+	}
+}
+
+func TestRateLimitSettings(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoint  config.EndpointConfig
+		wantLimit rate.Limit
+		wantBurst int
+	}{
+		{name: "defaults", wantLimit: defaultRateLimit, wantBurst: defaultBurst},
+		{name: "rate only", endpoint: config.EndpointConfig{RateLimit: 25}, wantLimit: 25, wantBurst: defaultBurst},
+		{name: "rate and burst", endpoint: config.EndpointConfig{RateLimit: 0.5, Burst: 3}, wantLimit: 0.5, wantBurst: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, burst := rateLimitSettings(tt.endpoint)
+			if limit != tt.wantLimit || burst != tt.wantBurst {
+				t.Fatalf("settings = %v/%d, want %v/%d", limit, burst, tt.wantLimit, tt.wantBurst)
+			}
+		})
+	}
+}
+
+func TestAcquireTakesOneTokenAndQuotaUnit(t *testing.T) {
+	pool := newTestServerPool(t, nil, withProvider(func(e *config.EndpointConfig) {
+		e.RateLimit = 0.001
+		e.Burst = 3
+		e.Quota.Daily = 2
+	}))
+	endpoint := pool.endpoints()[0]
+
+	for i := 0; i < 2; i++ {
+		if !pool.acquire(endpoint) {
+			t.Fatalf("acquire #%d refused", i)
+		}
+		endpoint.circuitBreaker.RecordSuccess()
+	}
+	if tokens := endpoint.rateLimiter.Tokens(); tokens < 0.5 || tokens > 1.5 {
+		t.Fatalf("tokens left = %v, want 1", tokens)
+	}
+	if pool.acquire(endpoint) {
+		t.Fatal("acquire succeeded with the daily quota used up")
+	}
+	if usage := endpoint.quota.Usage(); usage.DailyRemaining != 0 {
+		t.Fatalf("daily quota left = %d, want 0", usage.DailyRemaining)
+	}
+}
@@ -0,0 +1,87 @@
+package endpoints
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+// reloadTestChain returns a chain with the named endpoints.
+func reloadTestChain(breaker config.CircuitBreakerConfig, names ...string) config.ChainConfig {
+	chain := config.ChainConfig{
+		Network:        "test",
+		ChainID:        1,
+		MaxBlockLag:    10,
+		CircuitBreaker: breaker,
+	}
+	for _, name := range names {
+		chain.Endpoints = append(chain.Endpoints, testEndpoint(name, unreachableURL))
+	}
+	return chain
+}
+
+func TestReloadUpdatesBreakerSettingsAndKeepsState(t *testing.T) {
+	pool := newTestPool(t, reloadTestChain(config.CircuitBreakerConfig{FailureThreshold: 5}, "a"))
+	breaker := pool.endpoints()[0].circuitBreaker
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+
+	if err := pool.ReloadConfig(reloadTestChain(config.CircuitBreakerConfig{FailureThreshold: 3}, "a")); err != nil {
+		t.Fatalf("ReloadConfig: %v", err)
+	}
+	if pool.endpoints()[0].circuitBreaker != breaker {
+		t.Fatal("breaker of the surviving endpoint was replaced")
+	}
+	if breaker.GetMetrics().CircuitState != StateClosed {
+		t.Fatal("breaker tripped by the reload")
+	}
+	breaker.RecordFailure()
+	if state := breaker.GetMetrics().CircuitState; state != StateOpen {
+		t.Fatalf("state after the third failure = %s, want the new threshold to trip it", state)
+	}
+}
+
+func TestReloadForgetsRemovedEndpoints(t *testing.T) {
+	pool := newTestPool(t, reloadTestChain(config.CircuitBreakerConfig{}, "a", "b"))
+
+	pool.recordHead("a", 100)
+	pool.recordHead("b", 200)
+	pool.metrics.UpdateMetrics(pool.Network, "b", 190, 200)
+	pool.metrics.UpdateFinalityMetrics(pool.Network, "b", 195, 10)
+	pool.metrics.SetFinalityStalled(pool.Network, "b", false)
+	pool.finality.update("b", 190, time.Now(), 0)
+	if !pool.heads.isDegraded("a") {
+		t.Fatal("a is not degraded behind b")
+	}
+
+	if err := pool.ReloadConfig(reloadTestChain(config.CircuitBreakerConfig{}, "a")); err != nil {
+		t.Fatalf("ReloadConfig: %v", err)
+	}
+
+	if pool.heads.isDegraded("a") {
+		t.Fatal("a is still degraded behind the removed endpoint")
+	}
+	if lag := testutil.ToFloat64(pool.metrics.BlockLag.WithLabelValues(pool.Network, "a")); lag != 0 {
+		t.Fatalf("lag of a = %v, want 0", lag)
+	}
+	for name, series := range map[string]struct {
+		count, want int
+	}{
+		"current_block_height": {testutil.CollectAndCount(pool.metrics.CurrentBlockHeight), 1},
+		"block_lag":            {testutil.CollectAndCount(pool.metrics.BlockLag), 1},
+		"finalized_blocks":     {testutil.CollectAndCount(pool.metrics.FinalizedBlocks), 0},
+		"safe_blocks":          {testutil.CollectAndCount(pool.metrics.SafeBlocks), 0},
+		"finality_gap":         {testutil.CollectAndCount(pool.metrics.FinalityGap), 0},
+		"finality_stalled":     {testutil.CollectAndCount(pool.metrics.FinalityStalled), 0},
+	} {
+		if series.count != series.want {
+			t.Errorf("%s has %d series, want %d", name, series.count, series.want)
+		}
+	}
+	if _, ok := pool.finality.finalized["b"]; ok {
+		t.Fatal("finality state of the removed endpoint is kept")
+	}
+}
@@ -0,0 +1,229 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultReorgWindow is the number of recent heights kept when the chain does not set reorg_window.
+const defaultReorgWindow = 64
+
+// blockHead identifies a block reported by an endpoint.
+type blockHead struct {
+	Number     uint64
+	Hash       string
+	ParentHash string
+}
+
+// rpcBlockHeader is the subset of a JSON-RPC block object used to build a blockHead.
+type rpcBlockHeader struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+}
+
+// toBlockHead converts a JSON-RPC block header into a blockHead.
+func (h rpcBlockHeader) toBlockHead() (blockHead, error) {
+	number, err := ParseHexUint64(h.Number)
+	if err != nil {
+		return blockHead{}, fmt.Errorf("failed to parse block number: %w", err)
+	}
+	return blockHead{Number: number, Hash: h.Hash, ParentHash: h.ParentHash}, nil
+}
+
+// latestBlock fetches the latest block header of an endpoint.
+func (ep *EndpointPool) latestBlock(ctx context.Context, endpoint Endpoint) (blockHead, error) {
+	var header rpcBlockHeader
+	if err := ep.callRPC(ctx, endpoint, "eth_getBlockByNumber", []interface{}{"latest", false}, &header); err != nil {
+		return blockHead{}, err
+	}
+	return header.toBlockHead()
+}
+
+// reorgEvent describes a block an endpoint replaced with a different hash.
+type reorgEvent struct {
+	height  uint64
+	depth   uint64
+	oldHash string
+	newHash string
+}
+
+// divergence describes two endpoints reporting different hashes at the same height.
+type divergence struct {
+	height    uint64
+	endpoints map[string]string // endpoint name to reported hash
+}
+
+// reorgDetector keeps a rolling window of the block hashes reported by each endpoint.
+type reorgDetector struct {
+	mu       sync.Mutex
+	window   uint64
+	hashes   map[string]map[uint64]string // endpoint -> height -> hash
+	maxSeen  map[string]uint64
+	diverged map[uint64]bool
+	top      uint64
+}
+
+// newReorgDetector creates a reorgDetector that remembers window heights below the highest head.
+func newReorgDetector(window uint64) *reorgDetector {
+	if window == 0 {
+		window = defaultReorgWindow
+	}
+	return &reorgDetector{
+		window:   window,
+		hashes:   make(map[string]map[uint64]string),
+		maxSeen:  make(map[string]uint64),
+		diverged: make(map[uint64]bool),
+	}
+}
+
+// observe records a block reported by an endpoint. It returns a reorg event when the
+// endpoint earlier reported a different hash for this height or for the parent height,
+// and a divergence when another endpoint reports a different hash for the same height.
+func (d *reorgDetector) observe(endpoint string, head blockHead) (*reorgEvent, *divergence) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	seen, ok := d.hashes[endpoint]
+	if !ok {
+		seen = make(map[uint64]string)
+		d.hashes[endpoint] = seen
+	}
+
+	var reorg *reorgEvent
+	if old, ok := seen[head.Number]; ok && old != head.Hash {
+		reorg = &reorgEvent{height: head.Number, oldHash: old, newHash: head.Hash}
+	} else if head.Number > 0 && head.ParentHash != "" {
+		if old, ok := seen[head.Number-1]; ok && old != head.ParentHash {
+			reorg = &reorgEvent{height: head.Number - 1, oldHash: old, newHash: head.ParentHash}
+		}
+	}
+	if reorg != nil {
+		reorg.depth = d.maxSeen[endpoint] - reorg.height + 1
+		// Forget the replaced blocks so the new chain is compared from here on
+		for h := range seen {
+			if h >= reorg.height {
+				delete(seen, h)
+			}
+		}
+		d.maxSeen[endpoint] = 0
+		if reorg.height > 0 {
+			d.maxSeen[endpoint] = reorg.height - 1
+		}
+		if reorg.height == head.Number-1 {
+			seen[reorg.height] = head.ParentHash
+		}
+	}
+
+	seen[head.Number] = head.Hash
+	if head.Number > d.maxSeen[endpoint] {
+		d.maxSeen[endpoint] = head.Number
+	}
+	if head.Number > d.top {
+		d.top = head.Number
+		d.prune()
+	}
+
+	var div *divergence
+	if !d.diverged[head.Number] {
+		for name, hashes := range d.hashes {
+			if other, ok := hashes[head.Number]; ok && name != endpoint && other != head.Hash {
+				d.diverged[head.Number] = true
+				div = &divergence{height: head.Number, endpoints: map[string]string{endpoint: head.Hash, name: other}}
+				break
+			}
+		}
+	}
+	return reorg, div
+}
+
+// prune drops heights that fell out of the window. Callers hold d.mu.
+func (d *reorgDetector) prune() {
+	if d.top < d.window {
+		return
+	}
+	cutoff := d.top - d.window
+	for _, seen := range d.hashes {
+		for h := range seen {
+			if h < cutoff {
+				delete(seen, h)
+			}
+		}
+	}
+	for h := range d.diverged {
+		if h < cutoff {
+			delete(d.diverged, h)
+		}
+	}
+}
+
+// retain forgets every endpoint that is not in names.
+func (d *reorgDetector) retain(names map[string]bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for name := range d.hashes {
+		if !names[name] {
+			delete(d.hashes, name)
+			delete(d.maxSeen, name)
+		}
+	}
+	d.resetTop()
+}
+
+// forget drops the blocks reported by an endpoint.
+func (d *reorgDetector) forget(endpoint string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.hashes, endpoint)
+	delete(d.maxSeen, endpoint)
+	d.resetTop()
+}
+
+// resetTop recomputes the highest head from the remaining endpoints, so heights of a
+// forgotten endpoint no longer decide which blocks are pruned. Callers hold d.mu.
+func (d *reorgDetector) resetTop() {
+	d.top = 0
+	for _, h := range d.maxSeen {
+		if h > d.top {
+			d.top = h
+		}
+	}
+}
+
+// recordBlock stores a block reported by an endpoint, updates its head and lag, and
+// reports reorgs and cross-endpoint divergence. Blocks of quarantined endpoints are ignored.
+func (ep *EndpointPool) recordBlock(endpoint string, head blockHead) {
+	if ep.chainIDs.isQuarantined(endpoint) {
+		return // Blocks of another chain would distort lag and divergence detection
+	}
+	ep.recordHead(endpoint, head.Number)
+	if head.Hash == "" {
+		return
+	}
+
+	reorg, div := ep.reorgs.observe(endpoint, head)
+	if reorg != nil {
+		ep.metrics.ObserveReorg(ep.Network, endpoint, float64(reorg.depth))
+		ep.logger.WithFields(logrus.Fields{
+			"network":  ep.Network,
+			"endpoint": endpoint,
+			"height":   reorg.height,
+			"depth":    reorg.depth,
+			"old_hash": reorg.oldHash,
+			"new_hash": reorg.newHash,
+		}).Warn("Chain reorganization detected")
+	}
+	if div != nil {
+		ep.metrics.RecordDivergence(ep.Network)
+		ep.logger.WithFields(logrus.Fields{
+			"network":   ep.Network,
+			"height":    div.height,
+			"endpoints": div.endpoints,
+		}).Warn("Endpoints disagree on the canonical block hash")
+	}
+}
@@ -0,0 +1,130 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+func TestReorgDetector(t *testing.T) {
+	type block struct {
+		endpoint string
+		head     blockHead
+	}
+	tests := []struct {
+		name          string
+		window        uint64
+		blocks        []block // the result of the last one is checked
+		wantReorg     *reorgEvent
+		wantDivergent uint64 // height of the divergence, 0 for none
+	}{
+		{
+			name: "linear chain",
+			blocks: []block{
+				{"a", blockHead{Number: 10, Hash: "0x10"}},
+				{"a", blockHead{Number: 11, Hash: "0x11", ParentHash: "0x10"}},
+			},
+		},
+		{
+			name: "block replaced",
+			blocks: []block{
+				{"a", blockHead{Number: 10, Hash: "0x10"}},
+				{"a", blockHead{Number: 11, Hash: "0x11", ParentHash: "0x10"}},
+				{"a", blockHead{Number: 10, Hash: "0x10b"}},
+			},
+			wantReorg: &reorgEvent{height: 10, depth: 2, oldHash: "0x10", newHash: "0x10b"},
+		},
+		{
+			name: "parent replaced",
+			blocks: []block{
+				{"a", blockHead{Number: 10, Hash: "0x10"}},
+				{"a", blockHead{Number: 11, Hash: "0x11", ParentHash: "0x10"}},
+				{"a", blockHead{Number: 12, Hash: "0x12", ParentHash: "0x11b"}},
+			},
+			wantReorg: &reorgEvent{height: 11, depth: 1, oldHash: "0x11", newHash: "0x11b"},
+		},
+		{
+			name: "replaced block is not reported twice",
+			blocks: []block{
+				{"a", blockHead{Number: 10, Hash: "0x10"}},
+				{"a", blockHead{Number: 10, Hash: "0x10b"}},
+				{"a", blockHead{Number: 11, Hash: "0x11", ParentHash: "0x10b"}},
+			},
+		},
+		{
+			name:   "blocks outside the window",
+			window: 4,
+			blocks: []block{
+				{"a", blockHead{Number: 1, Hash: "0x1"}},
+				{"a", blockHead{Number: 10, Hash: "0x10"}},
+				{"a", blockHead{Number: 1, Hash: "0x1b"}},
+			},
+		},
+		{
+			name: "endpoints agree",
+			blocks: []block{
+				{"a", blockHead{Number: 10, Hash: "0x10"}},
+				{"b", blockHead{Number: 10, Hash: "0x10"}},
+			},
+		},
+		{
+			name: "endpoints diverge",
+			blocks: []block{
+				{"a", blockHead{Number: 10, Hash: "0x10"}},
+				{"b", blockHead{Number: 10, Hash: "0x10b"}},
+			},
+			wantDivergent: 10,
+		},
+		{
+			name: "divergence reported once per height",
+			blocks: []block{
+				{"a", blockHead{Number: 10, Hash: "0x10"}},
+				{"b", blockHead{Number: 10, Hash: "0x10b"}},
+				{"c", blockHead{Number: 10, Hash: "0x10c"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newReorgDetector(tt.window)
+			var reorg *reorgEvent
+			var div *divergence
+			for _, b := range tt.blocks {
+				reorg, div = d.observe(b.endpoint, b.head)
+			}
+
+			switch {
+			case tt.wantReorg == nil && reorg != nil:
+				t.Fatalf("unexpected reorg %+v", *reorg)
+			case tt.wantReorg != nil && reorg == nil:
+				t.Fatal("reorg not detected")
+			case tt.wantReorg != nil && *reorg != *tt.wantReorg:
+				t.Fatalf("reorg = %+v, want %+v", *reorg, *tt.wantReorg)
+			}
+			switch {
+			case tt.wantDivergent == 0 && div != nil:
+				t.Fatalf("unexpected divergence %+v", *div)
+			case tt.wantDivergent != 0 && (div == nil || div.height != tt.wantDivergent):
+				t.Fatalf("divergence = %+v, want one at height %d", div, tt.wantDivergent)
+			}
+		})
+	}
+}
+
+func TestRecordBlockReportsReorgsAndDivergence(t *testing.T) {
+	pool := newTestPool(t, reloadTestChain(config.CircuitBreakerConfig{}, "a", "b"))
+
+	pool.recordBlock("a", blockHead{Number: 10, Hash: "0x10"})
+	pool.recordBlock("a", blockHead{Number: 11, Hash: "0x11", ParentHash: "0x10"})
+	pool.recordBlock("b", blockHead{Number: 11, Hash: "0x11b", ParentHash: "0x10"})
+	pool.recordBlock("a", blockHead{Number: 11, Hash: "0x11b", ParentHash: "0x10"})
+
+	if n := testutil.CollectAndCount(pool.metrics.ReorgDepth); n != 1 {
+		t.Fatalf("reorg depth has %d series, want one for a", n)
+	}
+	if n := testutil.ToFloat64(pool.metrics.ChainDivergences.WithLabelValues(pool.Network)); n != 1 {
+		t.Fatalf("divergences = %v, want 1", n)
+	}
+}
@@ -0,0 +1,194 @@
+package endpoints
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Default retry backoff values used when neither the chain nor the global settings set one.
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryBackoffMax = 30 * time.Second
+)
+
+// Supported retry policies as used in config.ChainConfig.RetryPolicy.
+const (
+	RetryPolicySame = "same" // retry against the endpoint that failed
+	RetryPolicyNext = "next" // retry against the next available endpoint in configuration order
+	RetryPolicyBest = "best" // retry against the endpoint chosen by the selection strategy
+)
+
+// Attempt records a single execution of a job against the endpoint it actually hit.
+type Attempt struct {
+	Endpoint string
+	At       time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// retryPolicyOrDefault returns the configured retry policy, defaulting to same.
+func retryPolicyOrDefault(policy string) string {
+	if policy == "" {
+		return RetryPolicySame
+	}
+	return policy
+}
+
+// attemptSummary formats the attempts of a job for logging.
+func attemptSummary(attempts []Attempt) []string {
+	summary := make([]string, len(attempts))
+	for i, a := range attempts {
+		result := "ok"
+		if a.Err != nil {
+			result = a.Err.Error()
+		}
+		summary[i] = fmt.Sprintf("%s (%s): %s", a.Endpoint, a.Duration.Round(time.Millisecond), result)
+	}
+	return summary
+}
+
+// backoff computes retry delays using exponential backoff with full jitter.
+type backoff struct {
+	base time.Duration
+	max  time.Duration
+	rng  *rand.Rand
+	mu   sync.Mutex
+}
+
+// newBackoff creates a backoff with the given base and cap, applying the defaults for unset values.
+func newBackoff(base, max time.Duration) *backoff {
+	if base <= 0 {
+		base = defaultRetryBackoff
+	}
+	if max <= 0 {
+		max = defaultRetryBackoffMax
+	}
+	if max < base {
+		max = base
+	}
+	return &backoff{
+		base: base,
+		max:  max,
+		rng:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Delay returns a random delay in [0, min(max, base*2^(attempt-1))] for the given attempt, starting at 1.
+func (b *backoff) Delay(attempt int) time.Duration {
+	ceiling := b.max
+	if attempt < 1 {
+		attempt = 1
+	}
+	// Stop doubling once the cap is reached to avoid overflowing the shift
+	if shift := attempt - 1; shift < 32 {
+		if d := b.base << uint(shift); d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Duration(b.rng.Int63n(int64(ceiling) + 1))
+}
+
+// scheduleRetry re-queues a failed job after a backoff delay without holding a worker.
+// The endpoint of the retry is chosen by the pool's retry policy.
+func (ep *EndpointPool) scheduleRetry(job Job) {
+	failed := job.Endpoint.Name
+	job.Retries++
+	job.Endpoint = ep.retryEndpoint(job.Endpoint)
+	ep.mu.RLock()
+	delay := ep.backoff.Delay(job.Retries)
+	policy := ep.RetryPolicy
+	ep.mu.RUnlock()
+	ep.metrics.JobRetries.WithLabelValues(ep.Network, job.Endpoint.Name).Inc()
+
+	ep.logger.WithFields(logrus.Fields{
+		"network":         ep.Network,
+		"failed_endpoint": failed,
+		"endpoint":        job.Endpoint.Name,
+		"retry_policy":    policy,
+		"attempt":         job.Retries,
+		"max":             job.MaxRetries,
+		"backoff":         delay.String(),
+	}).Info("Scheduling job retry")
+
+	time.AfterFunc(delay, func() {
+		if job.ctx.Err() != nil {
+			return
+		}
+		if !ep.enqueue(job) {
+			ep.logger.WithFields(logrus.Fields{
+				"network":  ep.Network,
+				"endpoint": job.Endpoint.Name,
+			}).Warn("Job queue is full or closed, dropping retry")
+		}
+	})
+}
+
+// retryEndpoint returns the endpoint a failed job should be retried against.
+// It falls back to the failed endpoint when no other endpoint is available.
+func (ep *EndpointPool) retryEndpoint(failed Endpoint) Endpoint {
+	ep.mu.RLock()
+	policy, current := ep.RetryPolicy, ep.Endpoints
+	ep.mu.RUnlock()
+
+	switch policy {
+	case RetryPolicyNext:
+		start := -1
+		for i, e := range current {
+			if e.Name == failed.Name {
+				start = i
+				break
+			}
+		}
+		for i := 1; i <= len(current); i++ {
+			e := current[(start+i+len(current))%len(current)]
+			if e.Name != failed.Name && ep.isAvailable(e) {
+				return e
+			}
+		}
+	case RetryPolicyBest:
+		candidates := make([]Endpoint, 0, len(current))
+		for _, e := range ep.availableEndpoints() {
+			if e.Name != failed.Name {
+				candidates = append(candidates, e)
+			}
+		}
+		if len(candidates) > 0 {
+			return candidates[ep.selectionStrategy().Select(candidates)]
+		}
+	}
+	return failed
+}
+
+// enqueue adds a job to the queue without blocking and reports whether it was accepted.
+func (ep *EndpointPool) enqueue(job Job) bool {
+	ep.queueMu.RLock()
+	defer ep.queueMu.RUnlock()
+
+	if ep.queueClosed {
+		return false
+	}
+	select {
+	case ep.JobQueue <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeQueue closes the job queue so that pending retries are dropped instead of panicking.
+func (ep *EndpointPool) closeQueue() {
+	ep.queueMu.Lock()
+	defer ep.queueMu.Unlock()
+
+	if !ep.queueClosed {
+		ep.queueClosed = true
+		close(ep.JobQueue)
+	}
+}
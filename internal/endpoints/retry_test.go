@@ -0,0 +1,87 @@
+package endpoints
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+func TestBackoffDelayBounds(t *testing.T) {
+	tests := []struct {
+		name        string
+		base, max   time.Duration
+		attempt     int
+		wantCeiling time.Duration
+	}{
+		{name: "first attempt", base: time.Second, max: 30 * time.Second, attempt: 1, wantCeiling: time.Second},
+		{name: "attempt below one", base: time.Second, max: 30 * time.Second, attempt: 0, wantCeiling: time.Second},
+		{name: "doubles per attempt", base: time.Second, max: 30 * time.Second, attempt: 4, wantCeiling: 8 * time.Second},
+		{name: "capped", base: time.Second, max: 30 * time.Second, attempt: 6, wantCeiling: 30 * time.Second},
+		{name: "no overflow", base: time.Second, max: 30 * time.Second, attempt: 100, wantCeiling: 30 * time.Second},
+		{name: "defaults", attempt: 1, wantCeiling: defaultRetryBackoff},
+		{name: "default cap", attempt: 100, wantCeiling: defaultRetryBackoffMax},
+		{name: "cap below base", base: time.Minute, max: time.Second, attempt: 3, wantCeiling: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackoff(tt.base, tt.max)
+			var longest time.Duration
+			for i := 0; i < 1000; i++ {
+				d := b.Delay(tt.attempt)
+				if d < 0 || d > tt.wantCeiling {
+					t.Fatalf("Delay(%d) = %s, want within [0, %s]", tt.attempt, d, tt.wantCeiling)
+				}
+				longest = max(longest, d)
+			}
+			// Full jitter spreads delays over the whole range
+			if longest < tt.wantCeiling/2 {
+				t.Fatalf("longest of 1000 delays = %s, want close to %s", longest, tt.wantCeiling)
+			}
+		})
+	}
+}
+
+func TestRetryEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		failed   string
+		disabled []string
+		want     string
+	}{
+		{name: "same", policy: RetryPolicySame, failed: "b", want: "b"},
+		{name: "default is same", failed: "b", want: "b"},
+		{name: "next", policy: RetryPolicyNext, failed: "b", want: "c"},
+		{name: "next wraps around", policy: RetryPolicyNext, failed: "c", want: "a"},
+		{name: "next skips unavailable", policy: RetryPolicyNext, failed: "a", disabled: []string{"b"}, want: "c"},
+		{name: "next without alternative", policy: RetryPolicyNext, failed: "a", disabled: []string{"b", "c"}, want: "a"},
+		{name: "best by strategy", policy: RetryPolicyBest, failed: "b", want: "c"},
+		{name: "best skips unavailable", policy: RetryPolicyBest, failed: "b", disabled: []string{"c"}, want: "a"},
+		{name: "best without alternative", policy: RetryPolicyBest, failed: "b", disabled: []string{"a", "c"}, want: "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := reloadTestChain(config.CircuitBreakerConfig{}, "a", "b", "c")
+			chain.RetryPolicy = tt.policy
+			chain.PoolingStrategy = StrategyPriority
+			for i, priority := range []int{3, 1, 2} {
+				chain.Endpoints[i].Priority = priority
+			}
+			pool := newTestPool(t, chain)
+			for _, name := range tt.disabled {
+				if _, err := pool.SetEndpointState(name, AdminDisabled, "test"); err != nil {
+					t.Fatalf("SetEndpointState: %v", err)
+				}
+			}
+
+			failed, err := pool.findEndpoint(tt.failed)
+			if err != nil {
+				t.Fatalf("findEndpoint: %v", err)
+			}
+			if got := pool.retryEndpoint(failed); got.Name != tt.want {
+				t.Fatalf("retry endpoint = %s, want %s", got.Name, tt.want)
+			}
+		})
+	}
+}
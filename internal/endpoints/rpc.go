@@ -0,0 +1,162 @@
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pampatzoglou/chain-view/internal/redact"
+)
+
+// rpcRequest is a JSON-RPC 2.0 request envelope.
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response envelope.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+}
+
+// RPCError is the error object returned by a JSON-RPC endpoint.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Error implements the error interface.
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// HTTPStatusError is returned when an endpoint answers with an HTTP status other than 200.
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+// Error implements the error interface.
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("non-200 response: %d", e.StatusCode)
+}
+
+// errEmptyResult is returned when a call succeeds without a result.
+var errEmptyResult = errors.New("empty result")
+
+// callRPC sends a JSON-RPC request to the endpoint and decodes the result into result.
+// WebSocket endpoints are called over their subscription connection.
+// A JSON-RPC error object is returned as an error even when the HTTP status is 200.
+func (ep *EndpointPool) callRPC(ctx context.Context, endpoint Endpoint, method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	var rpcResp rpcResponse
+	if endpoint.IsWebSocket() {
+		done := ep.trackRequest(endpoint.Name)
+		resp, err := endpoint.subscription.call(ctx, method, params)
+		done()
+		if err != nil {
+			return err
+		}
+		rpcResp = resp
+	} else {
+		body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+
+		status, respBody, err := ep.postRPC(ctx, endpoint, body)
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return &HTTPStatusError{StatusCode: status}
+		}
+
+		if err := json.Unmarshal(respBody, &rpcResp); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return decodeResult(rpcResp, method, result)
+}
+
+// decodeResult decodes the result of a JSON-RPC response into result. The error object
+// of the response and a missing result are returned as errors.
+func decodeResult(rpcResp rpcResponse, method string, result interface{}) error {
+	if rpcResp.Error != nil {
+		return rpcResp.Error
+	}
+	if len(rpcResp.Result) == 0 || string(rpcResp.Result) == "null" {
+		return fmt.Errorf("%w for %s", errEmptyResult, method)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+			return fmt.Errorf("failed to decode result: %w", err)
+		}
+	}
+	return nil
+}
+
+// maxResponseSize caps the size of an endpoint response read into memory.
+const maxResponseSize = 32 << 20
+
+// postRPC posts a raw JSON-RPC payload to the endpoint and returns the HTTP status and body.
+// The request counts as in flight for draining until the response is read.
+func (ep *EndpointPool) postRPC(ctx context.Context, endpoint Endpoint, payload []byte) (int, []byte, error) {
+	defer ep.trackRequest(endpoint.Name)()
+
+	client := &http.Client{
+		Timeout: endpoint.Timeout,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", redact.Error(err))
+	}
+	header, err := endpoint.auth.header()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to authenticate request: %w", err)
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("HTTP request failed: %w", redact.Error(err))
+	}
+	defer resp.Body.Close()
+
+	ep.metrics.HTTPResponseCodes.WithLabelValues(ep.Network, endpoint.Name, fmt.Sprintf("%d", resp.StatusCode)).Inc()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// ParseHexUint64 parses a 0x-prefixed hex quantity as returned by Ethereum JSON-RPC.
+func ParseHexUint64(s string) (uint64, error) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return 0, fmt.Errorf("invalid hex quantity %q", s)
+	}
+	v, err := strconv.ParseUint(s[2:], 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex quantity %q: %w", s, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,231 @@
+package endpoints
+
+import (
+	"sync"
+	"time"
+)
+
+// probeTracker keeps the outcome of the last probe of every endpoint and a moving
+// average of its request latency, for status reporting.
+type probeTracker struct {
+	mu        sync.Mutex
+	last      map[string]Attempt
+	latencies map[string]time.Duration
+}
+
+// newProbeTracker creates an empty probeTracker.
+func newProbeTracker() *probeTracker {
+	return &probeTracker{
+		last:      make(map[string]Attempt),
+		latencies: make(map[string]time.Duration),
+	}
+}
+
+// recordProbe stores the outcome of a probe.
+func (t *probeTracker) recordProbe(attempt Attempt) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last[attempt.Endpoint] = attempt
+}
+
+// observeLatency adds the latency of a successful request to the moving average.
+func (t *probeTracker) observeLatency(endpoint string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, ok := t.latencies[endpoint]
+	if !ok {
+		t.latencies[endpoint] = latency
+		return
+	}
+	t.latencies[endpoint] = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(prev))
+}
+
+// get returns the last probe and the average latency of an endpoint.
+func (t *probeTracker) get(endpoint string) (Attempt, bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, ok := t.last[endpoint]
+	return last, ok, t.latencies[endpoint]
+}
+
+// retain forgets every endpoint that is not in names.
+func (t *probeTracker) retain(names map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for name := range t.last {
+		if !names[name] {
+			delete(t.last, name)
+		}
+	}
+	for name := range t.latencies {
+		if !names[name] {
+			delete(t.latencies, name)
+		}
+	}
+}
+
+// PoolStatus is a snapshot of an endpoint pool for the admin API.
+type PoolStatus struct {
+	Network            string           `json:"network"`
+	ChainID            int              `json:"chain_id"`
+	PoolingStrategy    string           `json:"pooling_strategy"`
+	RetryPolicy        string           `json:"retry_policy"`
+	BestHead           uint64           `json:"best_head"`
+	EndpointCount      int              `json:"endpoint_count"`
+	AvailableEndpoints int              `json:"available_endpoints"`
+	Endpoints          []EndpointStatus `json:"endpoints,omitempty"`
+}
+
+// EndpointStatus is a snapshot of a single endpoint. The URL is redacted.
+type EndpointStatus struct {
+	Name           string                `json:"name"`
+	URL            string                `json:"url"`
+	Transport      string                `json:"transport"`
+	Weight         int                   `json:"weight"`
+	Priority       int                   `json:"priority"`
+	Available      bool                  `json:"available"`
+	AdminState     string                `json:"admin_state"` // enabled, draining or disabled
+	InFlight       int                   `json:"in_flight"`
+	Degraded       bool                  `json:"degraded"`    // lagging behind the best head
+	Quarantined    bool                  `json:"quarantined"` // serving another chain
+	Head           uint64                `json:"head"`
+	Lag            uint64                `json:"lag"`
+	LatencyMs      float64               `json:"latency_ms"` // moving average of successful requests
+	LastProbe      *ProbeStatus          `json:"last_probe,omitempty"`
+	CircuitBreaker CircuitBreakerStatus  `json:"circuit_breaker"`
+	RateLimit      RateLimitStatus       `json:"rate_limit"`
+	Quota          QuotaUsage            `json:"quota"`
+	Subscription   *SubscriptionSnapshot `json:"subscription,omitempty"`
+}
+
+// ProbeStatus is the outcome of the last probe of an endpoint.
+type ProbeStatus struct {
+	At         time.Time `json:"at"`
+	DurationMs float64   `json:"duration_ms"`
+	OK         bool      `json:"ok"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// CircuitBreakerStatus is the state and counters of an endpoint's circuit breaker.
+type CircuitBreakerStatus struct {
+	State     string `json:"state"`
+	Forced    bool   `json:"forced"`
+	Failures  int    `json:"failures"`
+	Successes int    `json:"successes"`
+}
+
+// RateLimitStatus is the configured rate limit of an endpoint and the tokens left in its bucket.
+type RateLimitStatus struct {
+	Limit     float64 `json:"limit"` // requests per second
+	Burst     int     `json:"burst"`
+	Available float64 `json:"available"`
+}
+
+// SubscriptionSnapshot is the JSON form of SubscriptionStatus.
+type SubscriptionSnapshot struct {
+	Connected        bool    `json:"connected"`
+	LastMessageAgeMs float64 `json:"last_message_age_ms"`
+	Head             uint64  `json:"head"`
+	Reconnects       int     `json:"reconnects"`
+}
+
+// Status returns a snapshot of the pool. Endpoint details are included when withEndpoints is set.
+func (ep *EndpointPool) Status(withEndpoints bool) PoolStatus {
+	ep.mu.RLock()
+	status := PoolStatus{
+		Network:         ep.Network,
+		ChainID:         ep.ChainID,
+		PoolingStrategy: ep.Strategy,
+		RetryPolicy:     ep.RetryPolicy,
+	}
+	current := ep.Endpoints
+	ep.mu.RUnlock()
+	if status.PoolingStrategy == "" {
+		status.PoolingStrategy = StrategyRoundRobin
+	}
+
+	heads, best := ep.heads.snapshot()
+	status.BestHead = best
+	status.EndpointCount = len(current)
+	for _, e := range current {
+		es := ep.endpointStatus(e, heads, best)
+		if es.Available {
+			status.AvailableEndpoints++
+		}
+		if withEndpoints {
+			status.Endpoints = append(status.Endpoints, es)
+		}
+	}
+	return status
+}
+
+// EndpointStatus returns a snapshot of the named endpoint.
+func (ep *EndpointPool) EndpointStatus(name string) (EndpointStatus, error) {
+	endpoint, err := ep.findEndpoint(name)
+	if err != nil {
+		return EndpointStatus{}, err
+	}
+	heads, best := ep.heads.snapshot()
+	return ep.endpointStatus(endpoint, heads, best), nil
+}
+
+// endpointStatus builds the snapshot of one endpoint.
+func (ep *EndpointPool) endpointStatus(e Endpoint, heads map[string]uint64, best uint64) EndpointStatus {
+	breaker := e.circuitBreaker.GetMetrics()
+	status := EndpointStatus{
+		Name:        e.Name,
+		URL:         e.RedactedURL(),
+		Transport:   "http",
+		Weight:      e.Weight,
+		Priority:    e.Priority,
+		Available:   ep.isAvailable(e),
+		AdminState:  ep.control.state(e.Name),
+		InFlight:    ep.control.inFlight(e.Name),
+		Degraded:    ep.heads.isDegraded(e.Name),
+		Quarantined: ep.chainIDs.isQuarantined(e.Name),
+		CircuitBreaker: CircuitBreakerStatus{
+			State:     breaker.CircuitState,
+			Forced:    breaker.Forced,
+			Failures:  breaker.Failures,
+			Successes: breaker.Successes,
+		},
+		RateLimit: RateLimitStatus{
+			Limit:     float64(e.rateLimiter.Limit()),
+			Burst:     e.rateLimiter.Burst(),
+			Available: e.rateLimiter.Tokens(),
+		},
+		Quota: e.quota.Usage(),
+	}
+	if head, ok := heads[e.Name]; ok {
+		status.Head = head
+		status.Lag = best - head
+	}
+
+	last, probed, latency := ep.probes.get(e.Name)
+	status.LatencyMs = durationMs(latency)
+	if probed {
+		status.LastProbe = &ProbeStatus{At: last.At, DurationMs: durationMs(last.Duration), OK: last.Err == nil}
+		if last.Err != nil {
+			status.LastProbe.Error = last.Err.Error()
+		}
+	}
+
+	if e.IsWebSocket() {
+		status.Transport = "websocket"
+		sub := e.subscription.Status()
+		status.Subscription = &SubscriptionSnapshot{
+			Connected:        sub.Connected,
+			LastMessageAgeMs: durationMs(sub.LastMessageAge),
+			Head:             sub.Head,
+			Reconnects:       sub.Reconnects,
+		}
+	}
+	return status
+}
+
+// durationMs converts a duration to fractional milliseconds.
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
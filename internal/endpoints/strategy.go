@@ -0,0 +1,178 @@
+package endpoints
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+// Supported pooling strategy names as used in config.ChainConfig.PoolingStrategy.
+const (
+	StrategyRoundRobin   = config.PoolingRoundRobin
+	StrategyWeighted     = config.PoolingWeighted
+	StrategyPriority     = config.PoolingPriority
+	StrategyRandom       = config.PoolingRandom
+	StrategyLeastLatency = config.PoolingLeastLatency
+)
+
+// SelectionStrategy picks the next endpoint out of a set of candidates.
+// Implementations must be safe for concurrent use.
+type SelectionStrategy interface {
+	// Select returns the index of the chosen endpoint in candidates.
+	// candidates is never empty.
+	Select(candidates []Endpoint) int
+	// Observe reports the outcome of a request so that adaptive strategies can learn from it.
+	Observe(endpoint Endpoint, latency time.Duration, err error)
+}
+
+// strategies maps each supported strategy name to its constructor.
+var strategies = map[string]func() SelectionStrategy{
+	StrategyRoundRobin:   func() SelectionStrategy { return &roundRobinStrategy{} },
+	StrategyWeighted:     func() SelectionStrategy { return &weightedStrategy{current: make(map[string]int)} },
+	StrategyPriority:     func() SelectionStrategy { return &priorityStrategy{} },
+	StrategyRandom:       func() SelectionStrategy { return &randomStrategy{rng: rand.New(rand.NewSource(time.Now().UnixNano()))} },
+	StrategyLeastLatency: func() SelectionStrategy { return &leastLatencyStrategy{latencies: make(map[string]time.Duration)} },
+}
+
+// StrategyNames returns the names of the implemented strategies in sorted order.
+func StrategyNames() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewSelectionStrategy returns the strategy registered under name.
+// An empty name selects round-robin.
+func NewSelectionStrategy(name string) (SelectionStrategy, error) {
+	if name == "" {
+		name = StrategyRoundRobin
+	}
+	newStrategy, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown pooling strategy %q", name)
+	}
+	return newStrategy(), nil
+}
+
+// roundRobinStrategy cycles through the candidates in order.
+type roundRobinStrategy struct {
+	mu      sync.Mutex
+	current int
+}
+
+func (s *roundRobinStrategy) Select(candidates []Endpoint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = (s.current + 1) % len(candidates)
+	return s.current
+}
+
+func (s *roundRobinStrategy) Observe(Endpoint, time.Duration, error) {}
+
+// weightedStrategy implements smooth weighted round-robin, so an endpoint with
+// weight 3 is chosen three times as often as one with weight 1 without bursts.
+type weightedStrategy struct {
+	mu      sync.Mutex
+	current map[string]int
+}
+
+func (s *weightedStrategy) Select(candidates []Endpoint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total, best := 0, 0
+	for i, e := range candidates {
+		w := e.effectiveWeight()
+		s.current[e.Name] += w
+		total += w
+		if s.current[e.Name] > s.current[candidates[best].Name] {
+			best = i
+		}
+	}
+	s.current[candidates[best].Name] -= total
+	return best
+}
+
+func (s *weightedStrategy) Observe(Endpoint, time.Duration, error) {}
+
+// priorityStrategy always picks the candidate with the lowest priority value,
+// falling back to the next one only when it is no longer a candidate.
+type priorityStrategy struct{}
+
+func (s *priorityStrategy) Select(candidates []Endpoint) int {
+	best := 0
+	for i, e := range candidates {
+		if e.Priority < candidates[best].Priority {
+			best = i
+		}
+	}
+	return best
+}
+
+func (s *priorityStrategy) Observe(Endpoint, time.Duration, error) {}
+
+// randomStrategy picks a uniformly random candidate.
+type randomStrategy struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func (s *randomStrategy) Select(candidates []Endpoint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Intn(len(candidates))
+}
+
+func (s *randomStrategy) Observe(Endpoint, time.Duration, error) {}
+
+// leastLatencyStrategy picks the candidate with the lowest moving average latency.
+// Endpoints that have not been measured yet are tried first.
+type leastLatencyStrategy struct {
+	mu        sync.Mutex
+	latencies map[string]time.Duration
+}
+
+// latencySmoothing is the weight given to a new sample in the moving average.
+const latencySmoothing = 0.3
+
+func (s *leastLatencyStrategy) Select(candidates []Endpoint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	best := -1
+	var bestLatency time.Duration
+	for i, e := range candidates {
+		latency, ok := s.latencies[e.Name]
+		if !ok {
+			return i
+		}
+		if best == -1 || latency < bestLatency {
+			best, bestLatency = i, latency
+		}
+	}
+	return best
+}
+
+func (s *leastLatencyStrategy) Observe(endpoint Endpoint, latency time.Duration, err error) {
+	if err != nil {
+		// Failed requests are handled by the circuit breaker, not the latency average.
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.latencies[endpoint.Name]
+	if !ok {
+		s.latencies[endpoint.Name] = latency
+		return
+	}
+	s.latencies[endpoint.Name] = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(prev))
+}
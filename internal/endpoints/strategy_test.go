@@ -0,0 +1,134 @@
+package endpoints
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pampatzoglou/chain-view/config"
+)
+
+func TestStrategyNamesMatchConfig(t *testing.T) {
+	accepted := append([]string(nil), config.PoolingStrategies...)
+	sort.Strings(accepted)
+	if names := StrategyNames(); !reflect.DeepEqual(names, accepted) {
+		t.Fatalf("implemented strategies %v, config accepts %v", names, accepted)
+	}
+}
+
+func TestStrategySelection(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   string
+		candidates []Endpoint
+		observe    func(s SelectionStrategy)
+		want       string // names of the selected endpoints in order
+	}{
+		{
+			name:       "default is round robin",
+			strategy:   "",
+			candidates: []Endpoint{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+			want:       "b c a b",
+		},
+		{
+			name:       "round robin",
+			strategy:   StrategyRoundRobin,
+			candidates: []Endpoint{{Name: "a"}, {Name: "b"}},
+			want:       "b a b a",
+		},
+		{
+			name:       "weighted by weight",
+			strategy:   StrategyWeighted,
+			candidates: []Endpoint{{Name: "a", Weight: 3}, {Name: "b", Weight: 1}},
+			want:       "a a b a a a b a",
+		},
+		{
+			name:       "weighted without weight",
+			strategy:   StrategyWeighted,
+			candidates: []Endpoint{{Name: "a"}, {Name: "b", Weight: -1}},
+			want:       "a b a b",
+		},
+		{
+			name:       "priority",
+			strategy:   StrategyPriority,
+			candidates: []Endpoint{{Name: "a", Priority: 2}, {Name: "b", Priority: 1}, {Name: "c", Priority: 1}},
+			want:       "b b b",
+		},
+		{
+			name:       "least latency tries unmeasured first",
+			strategy:   StrategyLeastLatency,
+			candidates: []Endpoint{{Name: "a"}, {Name: "b"}},
+			observe: func(s SelectionStrategy) {
+				s.Observe(Endpoint{Name: "a"}, 10*time.Millisecond, nil)
+			},
+			want: "b b",
+		},
+		{
+			name:       "least latency ignores failures",
+			strategy:   StrategyLeastLatency,
+			candidates: []Endpoint{{Name: "a"}, {Name: "b"}},
+			observe: func(s SelectionStrategy) {
+				s.Observe(Endpoint{Name: "a"}, 10*time.Millisecond, nil)
+				s.Observe(Endpoint{Name: "b"}, 5*time.Millisecond, nil)
+				s.Observe(Endpoint{Name: "b"}, time.Millisecond, errors.New("failed"))
+			},
+			want: "b b",
+		},
+		{
+			name:       "least latency moving average",
+			strategy:   StrategyLeastLatency,
+			candidates: []Endpoint{{Name: "a"}, {Name: "b"}},
+			observe: func(s SelectionStrategy) {
+				s.Observe(Endpoint{Name: "a"}, 10*time.Millisecond, nil)
+				s.Observe(Endpoint{Name: "b"}, 5*time.Millisecond, nil)
+				// The average of b moves to 5ms*0.7 + 30ms*0.3 = 12.5ms
+				s.Observe(Endpoint{Name: "b"}, 30*time.Millisecond, nil)
+			},
+			want: "a a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSelectionStrategy(tt.strategy)
+			if err != nil {
+				t.Fatalf("NewSelectionStrategy: %v", err)
+			}
+			if tt.observe != nil {
+				tt.observe(s)
+			}
+			var got []string
+			for range strings.Fields(tt.want) {
+				got = append(got, tt.candidates[s.Select(tt.candidates)].Name)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Fatalf("selected %s, want %s", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomStrategyPicksEveryCandidate(t *testing.T) {
+	s, err := NewSelectionStrategy(StrategyRandom)
+	if err != nil {
+		t.Fatalf("NewSelectionStrategy: %v", err)
+	}
+	candidates := []Endpoint{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	picked := make(map[int]int)
+	for i := 0; i < 300; i++ {
+		picked[s.Select(candidates)]++
+	}
+	for i := range candidates {
+		if picked[i] == 0 {
+			t.Fatalf("%s was never picked in 300 selections", candidates[i].Name)
+		}
+	}
+}
+
+func TestUnknownStrategy(t *testing.T) {
+	if _, err := NewSelectionStrategy("fastest"); err == nil {
+		t.Fatal("unknown strategy accepted")
+	}
+}
@@ -0,0 +1,385 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pampatzoglou/chain-view/internal/redact"
+	"github.com/sirupsen/logrus"
+)
+
+// Default subscription settings used when the endpoint does not configure them.
+const (
+	defaultStaleTimeout     = time.Minute
+	defaultDialTimeout      = 10 * time.Second
+	reconnectBackoffBase    = time.Second
+	reconnectBackoffMax     = time.Minute
+	subscribeRequestID      = 0
+	subscriptionNotifMethod = "eth_subscription"
+)
+
+// errNotConnected is returned for calls on a subscription without a live connection.
+var errNotConnected = errors.New("websocket not connected")
+
+// errSubscriptionStale is returned for the head of a subscription that stopped receiving messages.
+var errSubscriptionStale = errors.New("subscription stale")
+
+// isWebSocketURL reports whether the URL uses the ws or wss scheme.
+func isWebSocketURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "ws" || scheme == "wss"
+}
+
+// subscription keeps a persistent WebSocket connection to an endpoint with an
+// eth_subscribe newHeads subscription. It also multiplexes JSON-RPC calls over
+// the same connection.
+type subscription struct {
+	pool         *EndpointPool
+	endpoint     string
+	url          string
+	auth         *endpointAuth
+	timeout      time.Duration
+	staleTimeout time.Duration
+
+	mu          sync.Mutex
+	conn        *websocket.Conn
+	lastMessage time.Time
+	head        blockHead
+	reconnects  int
+	nextID      int
+	pending     map[int]chan wsResult
+	writeMu     sync.Mutex
+	cancel      context.CancelFunc
+}
+
+// wsResult is the outcome of a call multiplexed over the connection.
+type wsResult struct {
+	resp rpcResponse
+	err  error
+}
+
+// SubscriptionStatus is a snapshot of the health of a WebSocket subscription.
+type SubscriptionStatus struct {
+	Connected      bool
+	LastMessageAge time.Duration
+	Head           uint64
+	Reconnects     int
+}
+
+// newSubscription creates a subscription for a WebSocket endpoint. It is started by run.
+func (ep *EndpointPool) newSubscription(name, rawURL string, auth *endpointAuth, timeout, staleTimeout time.Duration) *subscription {
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+	if staleTimeout <= 0 {
+		staleTimeout = defaultStaleTimeout
+	}
+	return &subscription{
+		pool:         ep,
+		endpoint:     name,
+		url:          rawURL,
+		auth:         auth,
+		timeout:      timeout,
+		staleTimeout: staleTimeout,
+		pending:      make(map[int]chan wsResult),
+	}
+}
+
+// start runs the subscription in the background until ctx is canceled or stop is called.
+func (s *subscription) start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+	go s.run(ctx)
+}
+
+// stop ends a subscription started with start, closing its connection.
+func (s *subscription) stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// run keeps the subscription connected until ctx is canceled, reconnecting with backoff.
+func (s *subscription) run(ctx context.Context) {
+	retry := newBackoff(reconnectBackoffBase, reconnectBackoffMax)
+	attempt := 0
+
+	for ctx.Err() == nil {
+		receivedHeads, err := s.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if receivedHeads {
+			attempt = 0
+		}
+		attempt++
+
+		s.mu.Lock()
+		s.reconnects++
+		s.mu.Unlock()
+		s.pool.metrics.WSReconnects.WithLabelValues(s.pool.Network, s.endpoint).Inc()
+
+		delay := retry.Delay(attempt)
+		s.pool.logger.WithError(err).WithFields(logrus.Fields{
+			"network":  s.pool.Network,
+			"endpoint": s.endpoint,
+			"attempt":  attempt,
+			"backoff":  delay.String(),
+		}).Warn("WebSocket subscription lost, reconnecting")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// session dials the endpoint, subscribes to new heads and reads messages until the
+// connection fails or goes stale. It reports whether any head was received.
+func (s *subscription) session(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	auth := s.auth
+	s.mu.Unlock()
+	header, err := auth.header()
+	if err != nil {
+		return false, fmt.Errorf("failed to authenticate: %w", err)
+	}
+	dialer := websocket.Dialer{HandshakeTimeout: s.timeout}
+	conn, _, err := dialer.DialContext(ctx, s.url, header)
+	if err != nil {
+		return false, fmt.Errorf("dial failed: %w", redact.Error(err))
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	s.conn = conn
+	s.lastMessage = now
+	s.head = blockHead{} // A head of the previous session may be far behind
+	s.mu.Unlock()
+	defer s.disconnect()
+
+	// Close the connection when it goes stale or the context ends, which unblocks the read loop
+	done := make(chan struct{})
+	defer close(done)
+	go s.watch(ctx, conn, done)
+
+	subscribe := rpcRequest{JSONRPC: "2.0", ID: subscribeRequestID, Method: "eth_subscribe", Params: []interface{}{"newHeads"}}
+	if err := s.write(subscribe); err != nil {
+		return false, fmt.Errorf("subscribe failed: %w", err)
+	}
+
+	receivedHeads := false
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return receivedHeads, fmt.Errorf("read failed: %w", err)
+		}
+
+		s.mu.Lock()
+		s.lastMessage = time.Now()
+		s.mu.Unlock()
+
+		var envelope struct {
+			ID     *int            `json:"id"`
+			Method string          `json:"method"`
+			Result json.RawMessage `json:"result"`
+			Error  *RPCError       `json:"error"`
+			Params struct {
+				Result rpcBlockHeader `json:"result"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(message, &envelope); err != nil {
+			continue
+		}
+
+		switch {
+		case envelope.Method == subscriptionNotifMethod:
+			head, err := envelope.Params.Result.toBlockHead()
+			if err != nil {
+				continue
+			}
+			receivedHeads = true
+			s.mu.Lock()
+			s.head = head
+			s.mu.Unlock()
+			s.pool.recordBlock(s.endpoint, head)
+		case envelope.ID != nil && *envelope.ID == subscribeRequestID:
+			if envelope.Error != nil {
+				return false, fmt.Errorf("subscribe rejected: %w", envelope.Error)
+			}
+		case envelope.ID != nil:
+			s.deliver(*envelope.ID, wsResult{resp: rpcResponse{Result: envelope.Result, Error: envelope.Error}})
+		}
+	}
+}
+
+// setAuth replaces the credentials used when dialing.
+func (s *subscription) setAuth(auth *endpointAuth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = auth
+}
+
+// watch closes conn when no message arrived within the stale timeout or ctx is canceled.
+func (s *subscription) watch(ctx context.Context, conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(s.staleTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			conn.Close()
+			return
+		case <-ticker.C:
+			age := s.Status().LastMessageAge
+			s.pool.metrics.WSLastMessageAge.WithLabelValues(s.pool.Network, s.endpoint).Set(age.Seconds())
+			if age > s.staleTimeout {
+				s.pool.logger.WithFields(logrus.Fields{
+					"network":          s.pool.Network,
+					"endpoint":         s.endpoint,
+					"last_message_age": age.String(),
+				}).Warn("WebSocket subscription is stale")
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// disconnect closes the connection and fails every pending call.
+func (s *subscription) disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	for id, ch := range s.pending {
+		ch <- wsResult{err: errNotConnected}
+		delete(s.pending, id)
+	}
+}
+
+// write sends a JSON message over the connection.
+func (s *subscription) write(v interface{}) error {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return errNotConnected
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	return conn.WriteJSON(v)
+}
+
+// deliver hands a response to the pending call with the given id.
+func (s *subscription) deliver(id int, result wsResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ch, ok := s.pending[id]; ok {
+		ch <- result
+		delete(s.pending, id)
+	}
+}
+
+// call sends a JSON-RPC request over the connection and waits for its response.
+func (s *subscription) call(ctx context.Context, method string, params []interface{}) (rpcResponse, error) {
+	s.mu.Lock()
+	if s.conn == nil {
+		s.mu.Unlock()
+		return rpcResponse{}, errNotConnected
+	}
+	s.nextID++
+	id := s.nextID
+	ch := make(chan wsResult, 1)
+	s.pending[id] = ch
+	s.mu.Unlock()
+
+	cancel := func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}
+
+	if err := s.write(rpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params}); err != nil {
+		cancel()
+		return rpcResponse{}, fmt.Errorf("websocket write failed: %w", err)
+	}
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	select {
+	case result := <-ch:
+		return result.resp, result.err
+	case <-ctx.Done():
+		cancel()
+		return rpcResponse{}, ctx.Err()
+	case <-timer.C:
+		cancel()
+		return rpcResponse{}, fmt.Errorf("websocket call %s timed out after %s", method, s.timeout)
+	}
+}
+
+// latestHead returns the most recent head, or an error when the subscription is unhealthy.
+func (s *subscription) latestHead() (blockHead, error) {
+	status := s.Status()
+	if !status.Connected {
+		return blockHead{}, errNotConnected
+	}
+	if status.LastMessageAge > s.staleTimeout {
+		return blockHead{}, fmt.Errorf("%w: last message %s ago", errSubscriptionStale, status.LastMessageAge.Round(time.Second))
+	}
+	if status.Head == 0 {
+		return blockHead{}, fmt.Errorf("no head received yet")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.head, nil
+}
+
+// healthy reports whether the subscription is connected and not stale.
+func (s *subscription) healthy() bool {
+	status := s.Status()
+	return status.Connected && status.LastMessageAge <= s.staleTimeout
+}
+
+// Status returns a snapshot of the subscription health.
+func (s *subscription) Status() SubscriptionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := SubscriptionStatus{
+		Connected:  s.conn != nil,
+		Head:       s.head.Number,
+		Reconnects: s.reconnects,
+	}
+	if !s.lastMessage.IsZero() {
+		status.LastMessageAge = time.Since(s.lastMessage)
+	}
+	return status
+}
@@ -0,0 +1,54 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSessionForgetsHeadOfPreviousSession(t *testing.T) {
+	// The server accepts the subscription but never sends a head
+	upgrader := websocket.Upgrader{}
+	url := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+
+	pool := newTestServerPool(t, nil)
+	s := pool.newSubscription("ws", "ws"+strings.TrimPrefix(url, "http"), nil, time.Second, time.Minute)
+	s.head = blockHead{Number: 100}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.session(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.Status().Connected {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if head, err := s.latestHead(); err == nil {
+		t.Fatalf("latestHead = %d from the previous session", head.Number)
+	}
+}
@@ -0,0 +1,370 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pampatzoglou/chain-view/config"
+	"github.com/pampatzoglou/chain-view/internal/database"
+	"github.com/pampatzoglou/chain-view/internal/endpoints"
+	"github.com/pampatzoglou/chain-view/internal/logging"
+	"github.com/pampatzoglou/chain-view/internal/metrics"
+)
+
+// defaultPollInterval is used when the chain does not set ingestion.poll_interval.
+const defaultPollInterval = 5 * time.Second
+
+// maxRewindDepth is how many recent block hashes are kept to follow a reorg back to the
+// fork point. Deeper reorgs are only partly undone.
+const maxRewindDepth = 128
+
+// codeMethodNotFound is the JSON-RPC error code of a method the endpoint does not implement.
+const codeMethodNotFound = -32601
+
+// Ingester stores the blocks and transactions of every chain with ingestion enabled,
+// reading them through the chain's endpoint pool.
+type Ingester struct {
+	db      *database.DB
+	logger  *logging.Logger
+	metrics *metrics.MetricsManager
+}
+
+// NewIngester creates an Ingester that writes to db.
+func NewIngester(db *database.DB, logger *logging.Logger, mm *metrics.MetricsManager) *Ingester {
+	return &Ingester{db: db, logger: logger, metrics: mm}
+}
+
+// chainState is the ingestion progress of one chain.
+type chainState struct {
+	pool          *endpoints.EndpointPool
+	loaded        bool // cursor read from the database
+	hasCursor     bool
+	cursor        database.IngestCursor
+	recent        map[uint64]string // hashes of recently stored blocks, by number
+	blockReceipts bool              // eth_getBlockReceipts is supported
+}
+
+// Run follows the head of the pool's chain until ctx is canceled, storing every block up
+// to the configured number of confirmations behind it. It resumes from the stored cursor,
+// or starts at start_block, or at the head when that is unset. Settings are read again
+// every round, so enabling or disabling ingestion takes effect without a restart.
+func (in *Ingester) Run(ctx context.Context, pool *endpoints.EndpointPool) {
+	state := &chainState{
+		pool:          pool,
+		recent:        make(map[uint64]string),
+		blockReceipts: true,
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		settings := pool.IngestionSettings()
+		if settings.Enabled {
+			if err := in.ingest(ctx, state, settings); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				in.metrics.IngestionErrors.WithLabelValues(pool.Network).Inc()
+				in.logger.WithError(err).WithFields(logrus.Fields{
+					"network":  pool.Network,
+					"chain_id": pool.ChainID,
+				}).Warn("Block ingestion failed")
+			}
+		}
+		timer.Reset(pollInterval(settings))
+	}
+}
+
+// pollInterval returns the configured poll interval, applying the default.
+func pollInterval(settings config.IngestionConfig) time.Duration {
+	if settings.PollInterval.Duration <= 0 {
+		return defaultPollInterval
+	}
+	return settings.PollInterval.Duration
+}
+
+// ingest stores every block from the cursor up to the confirmed head.
+func (in *Ingester) ingest(ctx context.Context, state *chainState, settings config.IngestionConfig) error {
+	pool := state.pool
+	if !state.loaded {
+		cursor, ok, err := in.db.LoadCursor(ctx, pool.ChainID)
+		if err != nil {
+			return fmt.Errorf("failed to load ingestion cursor: %w", err)
+		}
+		state.loaded, state.hasCursor, state.cursor = true, ok, cursor
+		if ok {
+			state.recent[cursor.BlockNumber] = cursor.BlockHash
+			in.logger.WithFields(logrus.Fields{
+				"network": pool.Network,
+				"block":   cursor.BlockNumber,
+			}).Info("Resuming block ingestion")
+		}
+	}
+
+	var headHex string
+	if err := pool.Call(ctx, "eth_blockNumber", nil, &headHex); err != nil {
+		return fmt.Errorf("failed to get block number: %w", err)
+	}
+	head, err := endpoints.ParseHexUint64(headHex)
+	if err != nil {
+		return fmt.Errorf("failed to parse block number: %w", err)
+	}
+	if head < settings.Confirmations {
+		return nil
+	}
+	target := head - settings.Confirmations
+
+	next := state.cursor.BlockNumber + 1
+	if !state.hasCursor {
+		next = settings.StartBlock
+		if next == 0 {
+			next = target
+		}
+	}
+
+	for rewinds := 0; next <= target; {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		block, err := in.fetchBlock(ctx, state, next)
+		if err != nil {
+			return err
+		}
+
+		if state.hasCursor && state.cursor.BlockHash != "" && block.ParentHash != state.cursor.BlockHash {
+			if rewinds++; rewinds > maxRewindDepth {
+				return fmt.Errorf("reorg at block %d is deeper than %d blocks", next, maxRewindDepth)
+			}
+			if err := in.rewind(ctx, state); err != nil {
+				return err
+			}
+			next = state.cursor.BlockNumber + 1
+			continue
+		}
+
+		if err := in.db.StoreBlock(ctx, block.Block); err != nil {
+			return fmt.Errorf("failed to store block %d: %w", next, err)
+		}
+		state.hasCursor = true
+		state.cursor = database.IngestCursor{BlockNumber: block.Number, BlockHash: block.Hash}
+		state.recent[block.Number] = block.Hash
+		if block.Number >= maxRewindDepth {
+			delete(state.recent, block.Number-maxRewindDepth)
+		}
+		in.metrics.IngestedBlock.WithLabelValues(pool.Network).Set(float64(block.Number))
+		in.metrics.IngestedTransactions.WithLabelValues(pool.Network).Add(float64(len(block.Transactions)))
+		in.logger.WithFields(logrus.Fields{
+			"network":      pool.Network,
+			"block":        block.Number,
+			"transactions": len(block.Transactions),
+		}).Debug("Ingested block")
+
+		rewinds = 0
+		next = block.Number + 1
+	}
+	return nil
+}
+
+// rewind removes the cursor block, which left the canonical chain, and moves the cursor to
+// its parent. The parent hash is unknown when it is older than the recent hashes, in which
+// case the next block is stored without checking it.
+func (in *Ingester) rewind(ctx context.Context, state *chainState) error {
+	orphaned := state.cursor
+	if orphaned.BlockNumber == 0 {
+		return errors.New("cannot rewind past the genesis block")
+	}
+	parent := database.IngestCursor{
+		BlockNumber: orphaned.BlockNumber - 1,
+		BlockHash:   state.recent[orphaned.BlockNumber-1],
+	}
+	if err := in.db.RewindBlock(ctx, state.pool.ChainID, state.pool.Network, orphaned.BlockNumber, parent); err != nil {
+		return fmt.Errorf("failed to rewind block %d: %w", orphaned.BlockNumber, err)
+	}
+	delete(state.recent, orphaned.BlockNumber)
+	state.cursor = parent
+
+	in.metrics.IngestionRewinds.WithLabelValues(state.pool.Network).Inc()
+	in.logger.WithFields(logrus.Fields{
+		"network": state.pool.Network,
+		"block":   orphaned.BlockNumber,
+		"hash":    orphaned.BlockHash,
+	}).Warn("Block left the canonical chain, removed its transactions")
+	return nil
+}
+
+// rpcBlock is a block returned by eth_getBlockByNumber with full transactions.
+type rpcBlock struct {
+	Number       string           `json:"number"`
+	Hash         string           `json:"hash"`
+	ParentHash   string           `json:"parentHash"`
+	Timestamp    string           `json:"timestamp"`
+	Transactions []rpcTransaction `json:"transactions"`
+}
+
+// rpcTransaction is a transaction of an rpcBlock.
+type rpcTransaction struct {
+	Hash     string  `json:"hash"`
+	From     string  `json:"from"`
+	To       *string `json:"to"` // null for contract creations
+	Value    string  `json:"value"`
+	GasPrice string  `json:"gasPrice"`
+}
+
+// rpcReceipt holds the fields used from a transaction receipt.
+type rpcReceipt struct {
+	TransactionHash   string `json:"transactionHash"`
+	BlockHash         string `json:"blockHash"`
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+}
+
+// fetchedBlock is a block ready to be stored, with the hash of its parent.
+type fetchedBlock struct {
+	database.Block
+	ParentHash string
+}
+
+// fetchBlock fetches a block with its transactions and their receipts.
+func (in *Ingester) fetchBlock(ctx context.Context, state *chainState, number uint64) (fetchedBlock, error) {
+	pool := state.pool
+	blockTag := "0x" + strconv.FormatUint(number, 16)
+
+	var raw rpcBlock
+	if err := pool.Call(ctx, "eth_getBlockByNumber", []interface{}{blockTag, true}, &raw); err != nil {
+		return fetchedBlock{}, fmt.Errorf("failed to get block %d: %w", number, err)
+	}
+	timestamp, err := endpoints.ParseHexUint64(raw.Timestamp)
+	if err != nil {
+		return fetchedBlock{}, fmt.Errorf("failed to parse timestamp of block %d: %w", number, err)
+	}
+
+	block := fetchedBlock{
+		Block: database.Block{
+			ChainID:   pool.ChainID,
+			Network:   pool.Network,
+			Number:    number,
+			Hash:      raw.Hash,
+			Timestamp: time.Unix(int64(timestamp), 0),
+		},
+		ParentHash: raw.ParentHash,
+	}
+	if len(raw.Transactions) == 0 {
+		return block, nil
+	}
+
+	receipts, err := in.fetchReceipts(ctx, state, blockTag, raw)
+	if err != nil {
+		return fetchedBlock{}, fmt.Errorf("failed to get receipts of block %d: %w", number, err)
+	}
+
+	for _, t := range raw.Transactions {
+		receipt, ok := receipts[t.Hash]
+		if !ok {
+			return fetchedBlock{}, fmt.Errorf("no receipt for transaction %s in block %d", t.Hash, number)
+		}
+		if receipt.BlockHash != raw.Hash {
+			return fetchedBlock{}, fmt.Errorf("receipt of transaction %s is from block %s, not %s", t.Hash, receipt.BlockHash, raw.Hash)
+		}
+		tx, err := convertTransaction(t, receipt)
+		if err != nil {
+			return fetchedBlock{}, fmt.Errorf("invalid transaction %s in block %d: %w", t.Hash, number, err)
+		}
+		block.Transactions = append(block.Transactions, tx)
+	}
+	return block, nil
+}
+
+// fetchReceipts returns the receipts of a block's transactions by transaction hash. It uses
+// eth_getBlockReceipts and falls back to one eth_getTransactionReceipt per transaction when
+// the endpoints do not implement that method.
+func (in *Ingester) fetchReceipts(ctx context.Context, state *chainState, blockTag string, block rpcBlock) (map[string]rpcReceipt, error) {
+	receipts := make(map[string]rpcReceipt, len(block.Transactions))
+
+	if state.blockReceipts {
+		var list []rpcReceipt
+		err := state.pool.Call(ctx, "eth_getBlockReceipts", []interface{}{blockTag}, &list)
+		var rpcErr *endpoints.RPCError
+		switch {
+		case err == nil:
+			for _, r := range list {
+				receipts[r.TransactionHash] = r
+			}
+			return receipts, nil
+		case errors.As(err, &rpcErr) && rpcErr.Code == codeMethodNotFound:
+			state.blockReceipts = false
+			in.logger.WithError(err).WithFields(logrus.Fields{
+				"network": state.pool.Network,
+			}).Info("eth_getBlockReceipts is not supported, fetching receipts per transaction")
+		default:
+			return nil, err
+		}
+	}
+
+	for _, t := range block.Transactions {
+		var r rpcReceipt
+		if err := state.pool.Call(ctx, "eth_getTransactionReceipt", []interface{}{t.Hash}, &r); err != nil {
+			return nil, err
+		}
+		receipts[t.Hash] = r
+	}
+	return receipts, nil
+}
+
+// convertTransaction combines a transaction with its receipt. The effective gas price of
+// the receipt is used when present, as the gas price of dynamic fee transactions is a cap.
+func convertTransaction(t rpcTransaction, receipt rpcReceipt) (database.Transaction, error) {
+	value, err := parseBig(t.Value)
+	if err != nil {
+		return database.Transaction{}, fmt.Errorf("invalid value: %w", err)
+	}
+	gasPriceHex := receipt.EffectiveGasPrice
+	if gasPriceHex == "" {
+		gasPriceHex = t.GasPrice
+	}
+	gasPrice, err := parseBig(gasPriceHex)
+	if err != nil {
+		return database.Transaction{}, fmt.Errorf("invalid gas price: %w", err)
+	}
+	gasUsed, err := endpoints.ParseHexUint64(receipt.GasUsed)
+	if err != nil {
+		return database.Transaction{}, fmt.Errorf("invalid gas used: %w", err)
+	}
+
+	tx := database.Transaction{
+		Hash:     t.Hash,
+		From:     strings.ToLower(t.From),
+		Value:    value,
+		GasPrice: gasPrice,
+		GasUsed:  gasUsed,
+	}
+	if t.To != nil {
+		tx.To = strings.ToLower(*t.To)
+	}
+	return tx, nil
+}
+
+// parseBig parses a 0x-prefixed hex quantity of any size.
+func parseBig(s string) (*big.Int, error) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return nil, fmt.Errorf("invalid hex quantity %q", s)
+	}
+	v, ok := new(big.Int).SetString(s[2:], 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex quantity %q", s)
+	}
+	return v, nil
+}
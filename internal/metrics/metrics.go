@@ -7,63 +7,362 @@ import (
 	"github.com/pampatzoglou/chain-view/internal/logging"
 )
 
-// Metrics for tracking finalized blocks and current block heights
-var (
-	FinalizedBlocks = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "finalized_blocks",
-			Help: "Number of finalized blocks per chain and provider",
-		},
-		[]string{"chain", "provider"},
-	)
+// MetricsManager owns every Prometheus collector of the service and registers them with
+// a single Registerer. One manager is shared by all chains; each series carries a chain
+// label, and endpoint-level series an endpoint label holding the endpoint name.
+type MetricsManager struct {
+	logger     *logging.Logger
+	registerer prometheus.Registerer
 
-	CurrentBlockHeight = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "current_block_height",
-			Help: "Current block height per chain and provider",
-		},
-		[]string{"chain", "provider"},
-	)
+	// Chain tracking
+	FinalizedBlocks    *prometheus.GaugeVec
+	CurrentBlockHeight *prometheus.GaugeVec
+	SafeBlocks         *prometheus.GaugeVec
+	FinalityGap        *prometheus.GaugeVec
+	FinalityStalled    *prometheus.GaugeVec
+	ReorgDepth         *prometheus.HistogramVec
+	ChainDivergences   *prometheus.CounterVec
 
-	JobRetries = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "job_retries_total",
-			Help: "Total number of retries for jobs per endpoint",
-		},
-		[]string{"chain", "endpoint"},
-	)
-)
+	// Endpoint pools
+	JobSuccesses              *prometheus.CounterVec
+	JobFailures               *prometheus.CounterVec
+	JobRetries                *prometheus.CounterVec
+	HTTPResponseCodes         *prometheus.CounterVec
+	ResponseDuration          *prometheus.HistogramVec
+	CircuitBreakerState       *prometheus.GaugeVec
+	CircuitBreakerTransitions *prometheus.CounterVec
+	QuotaRemaining            *prometheus.GaugeVec
+	WSReconnects              *prometheus.CounterVec
+	WSLastMessageAge          *prometheus.GaugeVec
+	BlockLag                  *prometheus.GaugeVec
+	QuorumMismatches          *prometheus.CounterVec
+	ChainIDMismatch           *prometheus.GaugeVec
+	ReportedChainID           *prometheus.GaugeVec
+	EndpointAdminState        *prometheus.GaugeVec
+	AdminActions              *prometheus.CounterVec
+	EndpointInfo              *prometheus.GaugeVec
 
-// MetricsManager handles the registration and updating of metrics
-type MetricsManager struct {
-	logger *logging.Logger
+	// Proxy
+	ProxyRequests  *prometheus.CounterVec
+	ProxyFailovers *prometheus.CounterVec
+
+	// Database
+	DBQueries             *prometheus.CounterVec
+	DBQueryDuration       *prometheus.HistogramVec
+	EndpointChecksWritten prometheus.Counter
+	EndpointChecksDropped prometheus.Counter
+
+	// Ingestion
+	IngestedBlock        *prometheus.GaugeVec
+	IngestedTransactions *prometheus.CounterVec
+	IngestionErrors      *prometheus.CounterVec
+	IngestionRewinds     *prometheus.CounterVec
+
+	// Balances
+	AddressBalance *prometheus.GaugeVec
+	BalanceChanges *prometheus.CounterVec
+	BalanceErrors  *prometheus.CounterVec
+
+	// Configuration
+	ConfigReloads           *prometheus.CounterVec
+	ConfigLastReloadSuccess prometheus.Gauge
 }
 
-// NewMetricsManager creates a new MetricsManager
-func NewMetricsManager(logger *logging.Logger) *MetricsManager {
+// NewMetricsManager creates a MetricsManager whose collectors are registered with reg.
+// A nil reg uses the default Prometheus registerer; tests can pass an isolated registry.
+func NewMetricsManager(logger *logging.Logger, reg prometheus.Registerer) *MetricsManager {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
 	return &MetricsManager{
-		logger: logger,
+		logger:     logger,
+		registerer: reg,
+
+		FinalizedBlocks: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_finalized_blocks",
+			Help: "Number of finalized blocks per chain and endpoint",
+		}, []string{"chain", "endpoint"}),
+		CurrentBlockHeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_current_block_height",
+			Help: "Current block height per chain and endpoint",
+		}, []string{"chain", "endpoint"}),
+		SafeBlocks: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_safe_blocks",
+			Help: "Number of the latest safe block per chain and endpoint",
+		}, []string{"chain", "endpoint"}),
+		FinalityGap: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_finality_gap_blocks",
+			Help: "Number of blocks between the latest and the finalized block per chain and endpoint",
+		}, []string{"chain", "endpoint"}),
+		FinalityStalled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_finality_stalled",
+			Help: "Whether the finalized block has not advanced within the chain's stall threshold (1 = stalled)",
+		}, []string{"chain", "endpoint"}),
+		ReorgDepth: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chainview_reorg_depth_blocks",
+			Help:    "Depth of chain reorganizations observed per chain and endpoint",
+			Buckets: []float64{1, 2, 3, 5, 8, 13, 21, 34, 64},
+		}, []string{"chain", "endpoint"}),
+		ChainDivergences: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_chain_divergences_total",
+			Help: "Total number of heights where endpoints reported different block hashes per chain",
+		}, []string{"chain"}),
+
+		JobSuccesses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_job_successes_total",
+			Help: "Total number of successful jobs",
+		}, []string{"chain", "endpoint"}),
+		JobFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_job_failures_total",
+			Help: "Total number of failed jobs",
+		}, []string{"chain", "endpoint"}),
+		JobRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_job_retries_total",
+			Help: "Total number of retries for jobs per endpoint",
+		}, []string{"chain", "endpoint"}),
+		HTTPResponseCodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_http_response_codes_total",
+			Help: "Total number of HTTP response codes by endpoint",
+		}, []string{"chain", "endpoint", "code"}),
+		ResponseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chainview_response_duration_seconds",
+			Help:    "Histogram of response durations by endpoint",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"chain", "endpoint"}),
+		CircuitBreakerState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_circuit_breaker_state",
+			Help: "Circuit breaker state by endpoint (0 = closed, 1 = half-open, 2 = open)",
+		}, []string{"chain", "endpoint"}),
+		CircuitBreakerTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_circuit_breaker_transitions_total",
+			Help: "Total number of circuit breaker state transitions by endpoint",
+		}, []string{"chain", "endpoint", "from", "to"}),
+		QuotaRemaining: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_endpoint_quota_remaining",
+			Help: "Remaining provider requests in the current quota period by endpoint",
+		}, []string{"chain", "endpoint", "period"}),
+		WSReconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_ws_reconnects_total",
+			Help: "Total number of WebSocket subscription reconnects by endpoint",
+		}, []string{"chain", "endpoint"}),
+		WSLastMessageAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_ws_last_message_age_seconds",
+			Help: "Seconds since the last message on the WebSocket subscription by endpoint",
+		}, []string{"chain", "endpoint"}),
+		BlockLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_endpoint_block_lag",
+			Help: "Number of blocks an endpoint is behind the best-known head of its chain",
+		}, []string{"chain", "endpoint"}),
+		QuorumMismatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_quorum_mismatches_total",
+			Help: "Total number of quorum reads in which an endpoint was outvoted",
+		}, []string{"chain", "endpoint"}),
+		ChainIDMismatch: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_endpoint_chain_id_mismatch",
+			Help: "Set to 1 while an endpoint is quarantined for reporting a chain ID other than the configured one",
+		}, []string{"chain", "endpoint"}),
+		ReportedChainID: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_endpoint_reported_chain_id",
+			Help: "Chain ID last reported by an endpoint",
+		}, []string{"chain", "endpoint"}),
+		EndpointAdminState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_endpoint_admin_state",
+			Help: "Rotation state of an endpoint set through the admin API (0=enabled, 1=draining, 2=disabled)",
+		}, []string{"chain", "endpoint"}),
+		AdminActions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_admin_actions_total",
+			Help: "Total number of endpoint changes made through the admin API by action",
+		}, []string{"chain", "endpoint", "action"}),
+		EndpointInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_endpoint_info",
+			Help: "Static information about each endpoint, always 1. The host label never contains credentials",
+		}, []string{"chain", "endpoint", "host", "transport"}),
+
+		ProxyRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_proxy_requests_total",
+			Help: "Total number of proxied JSON-RPC requests by chain and outcome",
+		}, []string{"chain", "outcome"}),
+		ProxyFailovers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_proxy_failovers_total",
+			Help: "Total number of proxied requests that were answered after failing over to another endpoint",
+		}, []string{"chain"}),
+
+		DBQueries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_db_queries_total",
+			Help: "Total number of database queries",
+		}, []string{"query"}),
+		DBQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chainview_db_query_duration_seconds",
+			Help:    "Duration of database queries",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"query"}),
+		EndpointChecksWritten: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chainview_endpoint_checks_written_total",
+			Help: "Total number of probe results written to the endpoint_checks table",
+		}),
+		EndpointChecksDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chainview_endpoint_checks_dropped_total",
+			Help: "Total number of probe results dropped because the write buffer was full or a write failed",
+		}),
+
+		IngestedBlock: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_ingested_block",
+			Help: "Number of the last block stored by ingestion",
+		}, []string{"chain"}),
+		IngestedTransactions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_ingested_transactions_total",
+			Help: "Total number of transactions stored by ingestion",
+		}, []string{"chain"}),
+		IngestionErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_ingestion_errors_total",
+			Help: "Total number of failed ingestion rounds",
+		}, []string{"chain"}),
+		IngestionRewinds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_ingestion_rewinds_total",
+			Help: "Total number of stored blocks removed by ingestion after a reorg",
+		}, []string{"chain"}),
+
+		AddressBalance: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chainview_address_balance",
+			Help: "Last read balance of a watched address in the chain's native currency",
+		}, []string{"chain", "address"}),
+		BalanceChanges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_balance_changes_total",
+			Help: "Total number of balance changes of watched addresses written to the balances table",
+		}, []string{"chain"}),
+		BalanceErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_balance_errors_total",
+			Help: "Total number of watched address balances that could not be read or stored",
+		}, []string{"chain"}),
+
+		ConfigReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chainview_config_reloads_total",
+			Help: "Total number of configuration reloads by result (success or failure)",
+		}, []string{"result"}),
+		ConfigLastReloadSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "chainview_config_last_reload_success_timestamp_seconds",
+			Help: "Unix time of the last successful configuration reload",
+		}),
 	}
 }
 
-// RegisterMetrics registers all metrics with Prometheus
+// RegisterMetrics registers all metrics with the manager's Registerer. It is called once at
+// startup; pools and handlers share the registered collectors instead of registering their own.
 func (mm *MetricsManager) RegisterMetrics() {
 	mm.logger.Info("Registering Prometheus metrics")
-	prometheus.MustRegister(JobRetries)
-	prometheus.MustRegister(FinalizedBlocks)
-	prometheus.MustRegister(CurrentBlockHeight)
+	mm.registerer.MustRegister(
+		mm.FinalizedBlocks,
+		mm.CurrentBlockHeight,
+		mm.SafeBlocks,
+		mm.FinalityGap,
+		mm.FinalityStalled,
+		mm.ReorgDepth,
+		mm.ChainDivergences,
+		mm.JobSuccesses,
+		mm.JobFailures,
+		mm.JobRetries,
+		mm.HTTPResponseCodes,
+		mm.ResponseDuration,
+		mm.CircuitBreakerState,
+		mm.CircuitBreakerTransitions,
+		mm.QuotaRemaining,
+		mm.WSReconnects,
+		mm.WSLastMessageAge,
+		mm.BlockLag,
+		mm.QuorumMismatches,
+		mm.ChainIDMismatch,
+		mm.ReportedChainID,
+		mm.EndpointAdminState,
+		mm.AdminActions,
+		mm.EndpointInfo,
+		mm.ProxyRequests,
+		mm.ProxyFailovers,
+		mm.DBQueries,
+		mm.DBQueryDuration,
+		mm.EndpointChecksWritten,
+		mm.EndpointChecksDropped,
+		mm.IngestedBlock,
+		mm.IngestedTransactions,
+		mm.IngestionErrors,
+		mm.IngestionRewinds,
+		mm.AddressBalance,
+		mm.BalanceChanges,
+		mm.BalanceErrors,
+		mm.ConfigReloads,
+		mm.ConfigLastReloadSuccess,
+	)
 
 	mm.logger.Info("Prometheus metrics registered successfully")
 }
 
 // UpdateMetrics updates the metrics with new values
-func (mm *MetricsManager) UpdateMetrics(chain, provider string, finalizedBlocks, currentHeight float64) {
+func (mm *MetricsManager) UpdateMetrics(chain, endpoint string, finalizedBlocks, currentHeight float64) {
 	mm.logger.WithFields(logrus.Fields{
 		"chain":           chain,
-		"provider":        provider,
+		"endpoint":        endpoint,
 		"finalizedBlocks": finalizedBlocks,
 		"currentHeight":   currentHeight,
 	}).Debug("Updating metrics")
-	FinalizedBlocks.WithLabelValues(chain, provider).Set(finalizedBlocks)
-	CurrentBlockHeight.WithLabelValues(chain, provider).Set(currentHeight)
+	mm.FinalizedBlocks.WithLabelValues(chain, endpoint).Set(finalizedBlocks)
+	mm.CurrentBlockHeight.WithLabelValues(chain, endpoint).Set(currentHeight)
+}
+
+// UpdateFinalityMetrics updates the safe block and the gap between the latest and finalized block
+func (mm *MetricsManager) UpdateFinalityMetrics(chain, endpoint string, safeBlocks, finalityGap float64) {
+	mm.logger.WithFields(logrus.Fields{
+		"chain":       chain,
+		"endpoint":    endpoint,
+		"safeBlocks":  safeBlocks,
+		"finalityGap": finalityGap,
+	}).Debug("Updating finality metrics")
+	mm.SafeBlocks.WithLabelValues(chain, endpoint).Set(safeBlocks)
+	mm.FinalityGap.WithLabelValues(chain, endpoint).Set(finalityGap)
+}
+
+// SetFinalityStalled flags whether finality has stalled for a chain and endpoint
+func (mm *MetricsManager) SetFinalityStalled(chain, endpoint string, stalled bool) {
+	value := 0.0
+	if stalled {
+		value = 1
+	}
+	mm.FinalityStalled.WithLabelValues(chain, endpoint).Set(value)
+}
+
+// DeleteFinalityMetrics removes the finality series of an endpoint that is no longer polled
+func (mm *MetricsManager) DeleteFinalityMetrics(chain, endpoint string) {
+	mm.FinalizedBlocks.DeleteLabelValues(chain, endpoint)
+	mm.SafeBlocks.DeleteLabelValues(chain, endpoint)
+	mm.FinalityGap.DeleteLabelValues(chain, endpoint)
+	mm.FinalityStalled.DeleteLabelValues(chain, endpoint)
+}
+
+// ObserveReorg records the depth of a chain reorganization seen by an endpoint
+func (mm *MetricsManager) ObserveReorg(chain, endpoint string, depth float64) {
+	mm.ReorgDepth.WithLabelValues(chain, endpoint).Observe(depth)
+}
+
+// RecordDivergence counts a height where endpoints of a chain disagree on the block hash
+func (mm *MetricsManager) RecordDivergence(chain string) {
+	mm.ChainDivergences.WithLabelValues(chain).Inc()
+}
+
+// SetChainIDMismatch flags whether an endpoint reports the wrong chain ID and records the reported one
+func (mm *MetricsManager) SetChainIDMismatch(chain, endpoint string, reported uint64, mismatch bool) {
+	value := 0.0
+	if mismatch {
+		value = 1
+	}
+	mm.ChainIDMismatch.WithLabelValues(chain, endpoint).Set(value)
+	mm.ReportedChainID.WithLabelValues(chain, endpoint).Set(float64(reported))
+}
+
+// RecordConfigReload counts a configuration reload and, when it succeeded, its time
+func (mm *MetricsManager) RecordConfigReload(success bool) {
+	if !success {
+		mm.ConfigReloads.WithLabelValues("failure").Inc()
+		return
+	}
+	mm.ConfigReloads.WithLabelValues("success").Inc()
+	mm.ConfigLastReloadSuccess.SetToCurrentTime()
 }
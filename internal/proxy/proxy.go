@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pampatzoglou/chain-view/internal/endpoints"
+	"github.com/pampatzoglou/chain-view/internal/logging"
+	"github.com/pampatzoglou/chain-view/internal/metrics"
+)
+
+// maxRequestSize caps the size of a client JSON-RPC request.
+const maxRequestSize = 5 << 20
+
+// JSON-RPC error codes returned by the proxy itself.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// Handler forwards client JSON-RPC requests to the endpoint pool of the requested chain.
+// The chain is taken from the {chain} path value and matches a pool's network name or chain ID.
+type Handler struct {
+	mu      sync.RWMutex
+	pools   map[string]*endpoints.EndpointPool
+	logger  *logging.Logger
+	metrics *metrics.MetricsManager
+}
+
+// NewHandler creates a proxy Handler for the given pools. Requests and failovers are
+// counted in the proxy collectors of mm.
+func NewHandler(pools []*endpoints.EndpointPool, logger *logging.Logger, mm *metrics.MetricsManager) *Handler {
+	h := &Handler{logger: logger, metrics: mm}
+	h.SetPools(pools)
+	return h
+}
+
+// SetPools replaces the pools requests are routed to, for example after a configuration reload.
+func (h *Handler) SetPools(pools []*endpoints.EndpointPool) {
+	byName := make(map[string]*endpoints.EndpointPool, len(pools)*2)
+	for _, pool := range pools {
+		byName[pool.Network] = pool
+		byName[strconv.Itoa(pool.ChainID)] = pool
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pools = byName
+}
+
+// pool returns the pool serving chain, looked up by network name or chain ID.
+func (h *Handler) pool(chain string) (*endpoints.EndpointPool, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	pool, ok := h.pools[chain]
+	return pool, ok
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	chain := r.PathValue("chain")
+	pool, ok := h.pool(chain)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeInvalidParams, "unknown chain: "+chain)
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, codeParseError, "request body too large")
+		return
+	}
+	if !json.Valid(payload) {
+		h.metrics.ProxyRequests.WithLabelValues(pool.Network, "invalid").Inc()
+		writeError(w, http.StatusBadRequest, codeParseError, "parse error")
+		return
+	}
+
+	if trimmed := bytes.TrimSpace(payload); len(trimmed) > 0 && trimmed[0] == '[' {
+		h.serveBatch(w, r, pool, trimmed)
+		return
+	}
+
+	result, err := pool.Forward(r.Context(), payload)
+	fields := logrus.Fields{
+		"network":  pool.Network,
+		"attempts": len(result.Attempts),
+	}
+	if err != nil {
+		h.metrics.ProxyRequests.WithLabelValues(pool.Network, "failed").Inc()
+		h.logger.WithError(err).WithFields(fields).Error("Failed to proxy JSON-RPC request")
+		status := http.StatusBadGateway
+		if errors.Is(err, r.Context().Err()) {
+			status = http.StatusGatewayTimeout
+		}
+		writeError(w, status, codeInternalError, "no healthy endpoint available")
+		return
+	}
+
+	h.metrics.ProxyRequests.WithLabelValues(pool.Network, "ok").Inc()
+	if len(result.Attempts) > 1 {
+		h.metrics.ProxyFailovers.WithLabelValues(pool.Network).Inc()
+	}
+	fields["endpoint"] = result.Endpoint
+	h.logger.WithFields(fields).Debug("Proxied JSON-RPC request")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(result.Body)
+}
+
+// ServeQuorum handles quorum reads: the request is sent to several endpoints of the
+// chain and only the answer the configured quorum agrees on is returned.
+func (h *Handler) ServeQuorum(w http.ResponseWriter, r *http.Request) {
+	chain := r.PathValue("chain")
+	pool, ok := h.pool(chain)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeInvalidParams, "unknown chain: "+chain)
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, codeParseError, "request body too large")
+		return
+	}
+	if trimmed := bytes.TrimSpace(payload); !json.Valid(trimmed) || len(trimmed) == 0 || trimmed[0] != '{' {
+		h.metrics.ProxyRequests.WithLabelValues(pool.Network, "invalid").Inc()
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "quorum reads accept a single JSON-RPC request")
+		return
+	}
+
+	result, err := pool.QuorumCall(r.Context(), payload)
+	fields := logrus.Fields{
+		"network":  pool.Network,
+		"agreeing": result.Agreeing,
+		"outvoted": result.Outvoted,
+		"failed":   result.Failed,
+	}
+	if err != nil {
+		h.metrics.ProxyRequests.WithLabelValues(pool.Network, "no_quorum").Inc()
+		h.logger.WithError(err).WithFields(fields).Error("Quorum read failed")
+		writeError(w, http.StatusBadGateway, codeInternalError, err.Error())
+		return
+	}
+
+	h.metrics.ProxyRequests.WithLabelValues(pool.Network, "quorum").Inc()
+	h.logger.WithFields(fields).Debug("Quorum read succeeded")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(result.Body)
+}
+
+// serveBatch forwards a JSON-RPC batch and writes the reassembled responses.
+func (h *Handler) serveBatch(w http.ResponseWriter, r *http.Request, pool *endpoints.EndpointPool, payload []byte) {
+	var requests []json.RawMessage
+	if err := json.Unmarshal(payload, &requests); err != nil {
+		h.metrics.ProxyRequests.WithLabelValues(pool.Network, "invalid").Inc()
+		writeError(w, http.StatusBadRequest, codeParseError, "parse error")
+		return
+	}
+	if len(requests) == 0 {
+		h.metrics.ProxyRequests.WithLabelValues(pool.Network, "invalid").Inc()
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "empty batch")
+		return
+	}
+
+	result := pool.ForwardBatch(r.Context(), requests)
+	outcome := "ok"
+	switch {
+	case result.Failed == len(result.Responses) && result.Failed > 0:
+		outcome = "failed"
+	case result.Failed > 0:
+		outcome = "partial"
+	}
+	h.metrics.ProxyRequests.WithLabelValues(pool.Network, outcome).Inc()
+
+	h.logger.WithFields(logrus.Fields{
+		"network":  pool.Network,
+		"size":     len(requests),
+		"failed":   result.Failed,
+		"attempts": len(result.Attempts),
+	}).Debug("Proxied JSON-RPC batch")
+
+	if len(result.Responses) == 0 {
+		// A batch of notifications gets no response body
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result.Responses)
+}
+
+// writeError writes a JSON-RPC error response with a null id.
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      nil,
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,87 @@
+package redact
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Placeholder replaces every secret removed from a URL.
+const Placeholder = "REDACTED"
+
+// minSecretSegmentLength is the shortest path segment treated as an API key.
+// Provider keys (Infura project IDs, Alchemy and QuickNode tokens) are 32 characters or longer.
+const minSecretSegmentLength = 16
+
+// URL returns raw with its credentials masked so it can be logged. The password of the
+// userinfo, every query value and every path segment that looks like an API key are
+// replaced with Placeholder, and the fragment is dropped. Unparseable URLs are fully masked.
+func URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return Placeholder
+	}
+
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Placeholder)
+		}
+	}
+
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		if isSecretSegment(segment) {
+			segments[i] = Placeholder
+		}
+	}
+	u.Path = strings.Join(segments, "/")
+	u.RawPath = ""
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query[key] = []string{Placeholder}
+		}
+		u.RawQuery = query.Encode()
+	}
+	u.Fragment, u.RawFragment = "", ""
+
+	return u.String()
+}
+
+// Error masks the URL embedded in err when it wraps a *url.Error, as returned by
+// net/http clients and URL parsing. The error is returned for convenient wrapping; call it
+// before wrapping, as the messages of errors that already wrap err are not changed.
+func Error(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = URL(urlErr.URL)
+	}
+	return err
+}
+
+// Host returns the host of raw without port or credentials, or an empty string when it
+// cannot be parsed. It is safe to use as a metric label.
+func Host(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// isSecretSegment reports whether a path segment looks like an API key: long and made only
+// of token characters. Long readable segments are masked too; that costs only readability.
+func isSecretSegment(segment string) bool {
+	if len(segment) < minSecretSegmentLength {
+		return false
+	}
+	for _, r := range segment {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS endpoint_daily_uptime;
+DROP TABLE IF EXISTS endpoint_checks;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS endpoint_checks (
+    id BIGSERIAL PRIMARY KEY,
+    checked_at TIMESTAMPTZ NOT NULL,
+    chain VARCHAR(64) NOT NULL,
+    chain_id INT NOT NULL,
+    endpoint VARCHAR(128) NOT NULL,
+    status VARCHAR(8) NOT NULL,  -- up, down, or skipped when the pool did not send the probe
+    http_code SMALLINT,          -- NULL for WebSocket probes and requests that got no response
+    latency_ms DOUBLE PRECISION NOT NULL,
+    block_height BIGINT,
+    error_class VARCHAR(32)      -- timeout, connection, rate_limited, http_status, rpc_error, invalid_response, stale, other, or for skipped probes circuit_open, quota_exhausted or local_rate_limit
+);
+
+CREATE INDEX IF NOT EXISTS idx_endpoint_checks_endpoint_checked_at ON endpoint_checks(chain, endpoint, checked_at);
+CREATE INDEX IF NOT EXISTS idx_endpoint_checks_checked_at ON endpoint_checks USING BRIN (checked_at);
+
+CREATE OR REPLACE VIEW endpoint_daily_uptime AS
+SELECT
+    chain,
+    endpoint,
+    DATE_TRUNC('day', checked_at) AS day,
+    COUNT(*) AS checks,
+    COUNT(*) FILTER (WHERE status = 'up') AS successful_checks,
+    ROUND(100.0 * COUNT(*) FILTER (WHERE status = 'up') / COUNT(*), 3) AS uptime_percent,
+    PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY latency_ms) FILTER (WHERE status = 'up') AS median_latency_ms
+FROM
+    endpoint_checks
+WHERE
+    status <> 'skipped'
+GROUP BY
+    chain, endpoint, day;
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS ingestion_cursors;
+
+DROP INDEX IF EXISTS idx_transactions_chain_id_block_number;
+
+DELETE FROM transactions WHERE to_address IS NULL;
+ALTER TABLE transactions ALTER COLUMN to_address SET NOT NULL;
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_chain_id_transaction_hash_key;
+ALTER TABLE transactions ADD CONSTRAINT transactions_transaction_hash_key UNIQUE (transaction_hash);
+ALTER TABLE transactions DROP COLUMN IF EXISTS chain_id;
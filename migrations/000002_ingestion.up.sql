@@ -0,0 +1,19 @@
+-- Transactions are ingested per chain, so a hash is only unique within its chain.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS chain_id INT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ALTER COLUMN chain_id DROP DEFAULT;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_transaction_hash_key;
+ALTER TABLE transactions ADD CONSTRAINT transactions_chain_id_transaction_hash_key UNIQUE (chain_id, transaction_hash);
+
+-- Contract creations have no recipient.
+ALTER TABLE transactions ALTER COLUMN to_address DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_chain_id_block_number ON transactions(chain_id, block_number);
+
+-- Last block ingested per chain, so ingestion resumes where it stopped.
+CREATE TABLE IF NOT EXISTS ingestion_cursors (
+    chain_id INT PRIMARY KEY,
+    network VARCHAR(64) NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
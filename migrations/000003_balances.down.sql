@@ -0,0 +1,30 @@
+DROP VIEW IF EXISTS address_balance_changes;
+CREATE VIEW address_balance_changes AS
+SELECT 
+    b.address_id, 
+    a.address, 
+    b.balance, 
+    b.timestamp
+FROM 
+    balances b
+JOIN 
+    addresses a ON b.address_id = a.id
+ORDER BY 
+    b.timestamp DESC;
+
+DROP VIEW IF EXISTS current_balances;
+CREATE VIEW current_balances AS
+SELECT 
+    a.address, 
+    a.current_balance
+FROM 
+    addresses a;
+
+DROP TABLE IF EXISTS address_current_balances;
+
+COMMENT ON COLUMN addresses.current_balance IS NULL;
+
+DROP TABLE IF EXISTS watched_addresses;
+
+DROP INDEX IF EXISTS idx_balances_chain_id_address_id_timestamp;
+ALTER TABLE balances DROP COLUMN IF EXISTS chain_id;
@@ -0,0 +1,51 @@
+-- Balances are tracked per chain.
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS chain_id INT NOT NULL DEFAULT 0;
+ALTER TABLE balances ALTER COLUMN chain_id DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS idx_balances_chain_id_address_id_timestamp ON balances(chain_id, address_id, timestamp);
+
+-- Addresses whose balance is tracked, in addition to those in the configuration.
+CREATE TABLE IF NOT EXISTS watched_addresses (
+    chain_id INT NOT NULL,
+    address VARCHAR(42) NOT NULL,
+    label VARCHAR(128),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (chain_id, address)
+);
+
+-- The current balance of an address on each chain it is tracked on.
+CREATE TABLE IF NOT EXISTS address_current_balances (
+    address_id INT NOT NULL REFERENCES addresses(id),
+    chain_id INT NOT NULL,
+    current_balance NUMERIC(38, 18) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (chain_id, address_id)
+);
+
+COMMENT ON COLUMN addresses.current_balance IS
+    'Last balance change of the address on any chain; see address_current_balances for the balance per chain';
+
+DROP VIEW IF EXISTS current_balances;
+CREATE VIEW current_balances AS
+SELECT 
+    a.address, 
+    c.current_balance,
+    c.chain_id
+FROM 
+    address_current_balances c
+JOIN 
+    addresses a ON c.address_id = a.id;
+
+CREATE OR REPLACE VIEW address_balance_changes AS
+SELECT 
+    b.address_id, 
+    a.address, 
+    b.balance, 
+    b.timestamp,
+    b.chain_id
+FROM 
+    balances b
+JOIN 
+    addresses a ON b.address_id = a.id
+ORDER BY 
+    b.timestamp DESC;